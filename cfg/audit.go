@@ -0,0 +1,75 @@
+package cfg
+
+import (
+	"time"
+)
+
+// 默认保留的配置变化记录数。
+const DefaultHistorySize = 100
+
+// 一次重新载入引起的配置变化。
+type Revision struct {
+	Time    time.Time
+	Changes []Change // 按配置键排序，敏感配置项的值已隐藏
+}
+
+// 设置记录配置变化的日志函数，如logs.Info、logs.Warn，默认为nil，不记录日志。
+// 默认配置对象同样不记录，需要时调用SetChangeLog(logs.Info)。
+func (c *Config) SetChangeLog(fn func(format string, v ...interface{})) {
+	c.mu.Lock()
+	c.changeLog = fn
+	c.mu.Unlock()
+}
+
+// 设置保留的配置变化记录数，默认为DefaultHistorySize，为0时不保留。
+func (c *Config) SetHistorySize(n int) {
+	if n < 0 {
+		n = 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.historySize = n
+	if len(c.history) > n {
+		c.history = append([]Revision(nil), c.history[len(c.history)-n:]...)
+	}
+}
+
+// 返回最近的配置变化记录，按时间先后排列。首次载入不记录，重新载入后配置没有变化时也不记录。
+func (c *Config) History() []Revision {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Revision(nil), c.history...)
+}
+
+// 比较重新载入前后的配置数据，记录日志和变化历史。
+func (c *Config) audit(old, new *snapshot) {
+	if old == nil {
+		return
+	}
+	changes := c.RedactChanges(Diff(old.data, new.data))
+	if len(changes) == 0 {
+		return
+	}
+	// 原值为加密值或引用了敏感配置项，新值不再如此时，IsSecret不能识别原值
+	for i, ch := range changes {
+		if (old.encrypted[ch.Key] || old.secrets[ch.Key]) && ch.Old != nil {
+			changes[i].Old = Redacted
+		}
+	}
+
+	c.mu.Lock()
+	if c.historySize > 0 {
+		if len(c.history) >= c.historySize {
+			c.history = append(c.history[:0:0], c.history[len(c.history)-c.historySize+1:]...)
+		}
+		c.history = append(c.history, Revision{Time: time.Now(), Changes: changes})
+	}
+	log := c.changeLog
+	c.mu.Unlock()
+
+	if log != nil {
+		for _, ch := range changes {
+			log("cfg: %s", ch)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package cfg
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	dir := tempDir(t)
+	file := writeFile(t, dir, "app.toml", "[mongo]\nmaxlink = 10\npassword = \"p1\"\nservers = \"a\"\n")
+	c, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.changeLog != nil {
+		t.Error("New should not log changes by default")
+	}
+	var lines []string
+	c.SetChangeLog(func(format string, v ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, v...))
+	})
+
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if h := c.History(); len(h) != 0 {
+		t.Errorf("unchanged reload recorded: %v", h)
+	}
+
+	writeFile(t, dir, "app.toml", "[mongo]\nmaxlink = 20\npassword = \"p2\"\ntimeout = 5\n")
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	h := c.History()
+	if len(h) != 1 || h[0].Time.IsZero() {
+		t.Fatalf("History = %v", h)
+	}
+	want := []Change{
+		{Key: "mongo.maxlink", Kind: ChangeModified, Old: int64(10), New: int64(20)},
+		{Key: "mongo.password", Kind: ChangeModified, Old: Redacted, New: Redacted},
+		{Key: "mongo.servers", Kind: ChangeRemoved, Old: "a"},
+		{Key: "mongo.timeout", Kind: ChangeAdded, New: int64(5)},
+	}
+	if !reflect.DeepEqual(h[0].Changes, want) {
+		t.Errorf("Changes = %v", h[0].Changes)
+	}
+	if len(lines) != 4 || lines[0] != "cfg: ~ mongo.maxlink = 10 -> 20" {
+		t.Errorf("log = %q", lines)
+	}
+	for _, l := range lines {
+		if strings.Contains(l, "p2") {
+			t.Errorf("secret logged: %s", l)
+		}
+	}
+
+	c.SetHistorySize(2)
+	for i := 0; i < 3; i++ {
+		writeFile(t, dir, "app.toml", fmt.Sprintf("n = %d\n", i))
+		if err := c.Reload(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h = c.History()
+	if len(h) != 2 || h[1].Changes[0].New != int64(2) {
+		t.Errorf("History = %v", h)
+	}
+}
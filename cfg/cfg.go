@@ -4,48 +4,357 @@
 package cfg
 
 import (
-	"github.com/betterjun/go-toml"
+	"math"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
 )
 
-// 配置数据
-var tree *toml.TomlTree
+// 配置对象，保存一组配置文件载入后的数据，可以并发访问。
+// 包级函数操作默认配置对象，见Default。
+type Config struct {
+	// 配置数据、环境变量或命令行参数设置每次变化后加1，用于配置项句柄的缓存，见IntVar。
+	// 位于结构体开头，保证32位平台上原子操作的对齐要求。
+	version uint64
 
-// 载入配置文件。
-func LoadConfig(file string) (err error) {
-	tree, err = toml.LoadFile(file)
-	if err != nil {
-		return err
+	// 配置数据，保存*snapshot，重新载入时整体替换。
+	current atomic.Value
+
+	// 环境变量覆盖设置，保存*envOverlay，未启用时为nil。
+	env atomic.Value
+
+	// 命令行参数设置的值，保存map[string]string，配置键 -> 参数值，见BindFlags。
+	flags atomic.Value
+
+	// 数组合并策略，默认为ArrayReplace。
+	arrayPolicy int32
+
+	// 保护files、subscribers、declared、strict、schemas、jsonSchemas、loader、secretKey、secrets、profile、remotes、overrides、edits、changeLog、historySize和history。
+	mu sync.Mutex
+
+	// 当前配置文件路径，按合并顺序排列。
+	files []string
+
+	// 配置变化订阅者。
+	subscribers []*subscriber
+
+	// 声明的配置项，见Declare。
+	declared map[string]declaration
+
+	// 严格模式，载入时检查声明的配置项。
+	strict bool
+
+	// 注册的配置结构，载入时校验，见RegisterSchema。
+	schemas map[string]reflect.Type
+
+	// 注册的JSON Schema文档，载入时校验，见RegisterJSONSchema。
+	jsonSchemas []interface{}
+
+	// 指定的配置文件解析器，为nil时按扩展名选择，见SetLoader。
+	loader Loader
+
+	// 解密密钥，为nil时从环境变量读取，见SetSecretKey。
+	secretKey []byte
+
+	// 标记为敏感的配置键，见MarkSecret。
+	secrets map[string]bool
+
+	// 选择的配置方案，为空串时从环境变量读取，见SetProfile。
+	profile string
+
+	// 远程配置源，按添加顺序覆盖在配置文件之上，见AddRemote。
+	remotes []*remote
+
+	// 代码设置的覆盖值，按设置顺序覆盖在远程配置之上，见Override。
+	overrides []*override
+
+	// Set设置的配置项，按设置顺序排列，见Save。
+	edits []*edit
+
+	// 记录配置变化的日志函数，见SetChangeLog。
+	changeLog func(format string, v ...interface{})
+
+	// 保留的配置变化记录数和最近的记录，见History。
+	historySize int
+	history     []Revision
+
+	// 等待通知订阅者的变化，notifying表示正在通知，见Reload。
+	changes   [][2]*snapshot
+	notifying bool
+
+	// 串行化重新载入。
+	reloadMu sync.Mutex
+}
+
+// 创建空的配置对象，需要调用LoadConfig载入配置文件。
+func New() *Config {
+	return &Config{historySize: DefaultHistorySize}
+}
+
+// 创建配置对象并载入配置文件。
+func Load(files ...string) (*Config, error) {
+	c := New()
+	if err := c.LoadConfig(files...); err != nil {
+		return nil, err
 	}
+	return c, nil
+}
+
+// 返回当前生效的配置快照。
+func (c *Config) getSnapshot() *snapshot {
+	s, _ := c.current.Load().(*snapshot)
+	return s
+}
+
+// 载入配置文件。可以按顺序指定多个文件，如base.toml、prod.toml、local.toml，
+// 各文件深度合并，后面的文件覆盖前面文件中的同名配置项。
+// 文件顶层的include = ["conf.d/*.toml"]包含其他文件，路径相对于当前文件，
+// 匹配的文件按文件名排序，在当前文件之后依次合并。
+// 字符串值中可以使用${other.key}引用其他配置项，${ENV_VAR:default}引用环境变量，
+// $${表示字面的${，引用在载入时解析。
+func (c *Config) LoadConfig(files ...string) (err error) {
+	c.mu.Lock()
+	c.files = files
+	c.mu.Unlock()
 
-	return nil
+	return c.Reload()
+}
+
+// 查找配置项，环境变量覆盖的值不在此处理。
+func (c *Config) getValue(key string) (interface{}, bool) {
+	snap := c.getSnapshot()
+	if snap == nil {
+		return nil, false
+	}
+	return lookup(snap.data, key)
+}
+
+// 查找配置项，依次使用命令行参数、环境变量、配置文件中的值，
+// env表示值为来自命令行参数或环境变量的字符串，需要按类型解析。
+// 配置项不存在时，hasDef为true返回errUseDef，由调用者使用读取时指定的默认值；
+// 否则使用Declare声明的默认值。
+func (c *Config) find(key string, hasDef bool) (v interface{}, env bool, err error) {
+	if s, ok := c.lookupOverride(key); ok {
+		return s, true, nil
+	}
+
+	snap := c.getSnapshot()
+	if snap != nil {
+		if v, ok := lookup(snap.data, key); ok {
+			return v, false, nil
+		}
+	}
+	if hasDef {
+		return nil, false, errUseDef
+	}
+	if v, ok := c.declaredDefault(key); ok {
+		return v, false, nil
+	}
+	if snap == nil {
+		return nil, false, ErrNotLoaded
+	}
+	return nil, false, &ErrKeyNotFound{Key: key}
 }
 
 // 获取字符串配置数据。
-func GetString(key string, def ...string) (ret string) {
-	return tree.GetString(key, def...)
+func (c *Config) GetString(key string, def ...string) (ret string) {
+	ret, err := c.GetStringE(key, def...)
+	if err != nil && len(def) > 0 {
+		return def[0]
+	}
+	return ret
+}
+
+// 获取字符串配置数据，配置项不存在且未指定默认值，或类型不符时返回错误。
+func (c *Config) GetStringE(key string, def ...string) (ret string, err error) {
+	v, _, err := c.find(key, len(def) > 0)
+	if err == errUseDef {
+		return def[0], nil
+	}
+	if err != nil {
+		return "", err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", typeMismatch(key, "string", v)
+	}
+	return s, nil
+}
+
+// 获取字符串配置数据，出错时panic。
+func (c *Config) MustGetString(key string) string {
+	ret, err := c.GetStringE(key)
+	if err != nil {
+		panic(err)
+	}
+	return ret
 }
 
 // 获取64位整数配置数据。
-func GetInt64(key string, def ...int64) (ret int64) {
-	return tree.GetInt64(key, def...)
+func (c *Config) GetInt64(key string, def ...int64) (ret int64) {
+	ret, err := c.GetInt64E(key, def...)
+	if err != nil && len(def) > 0 {
+		return def[0]
+	}
+	return ret
+}
+
+// 获取64位整数配置数据，配置项不存在且未指定默认值，或类型不符时返回错误。
+func (c *Config) GetInt64E(key string, def ...int64) (ret int64, err error) {
+	v, env, err := c.find(key, len(def) > 0)
+	if err == errUseDef {
+		return def[0], nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if env {
+		i, err := strconv.ParseInt(v.(string), 0, 64)
+		if err != nil {
+			return 0, typeMismatch(key, "int64", v)
+		}
+		return i, nil
+	}
+	i, ok := v.(int64)
+	if !ok {
+		return 0, typeMismatch(key, "int64", v)
+	}
+	return i, nil
+}
+
+// 获取64位整数配置数据，出错时panic。
+func (c *Config) MustGetInt64(key string) int64 {
+	ret, err := c.GetInt64E(key)
+	if err != nil {
+		panic(err)
+	}
+	return ret
+}
+
+// 获取32位整数配置数据，超出范围时返回默认值。
+func (c *Config) GetInt32(key string, def ...int64) (ret int32) {
+	ret, err := c.GetInt32E(key, def...)
+	if err != nil && len(def) > 0 {
+		return int32(def[0])
+	}
+	return ret
+}
+
+// 获取32位整数配置数据，配置项不存在且未指定默认值，类型不符或超出范围时返回错误。
+func (c *Config) GetInt32E(key string, def ...int64) (ret int32, err error) {
+	i, err := c.GetInt64E(key, def...)
+	if err != nil {
+		return 0, err
+	}
+	if i < math.MinInt32 || i > math.MaxInt32 {
+		return 0, &ErrOverflow{Key: key, Value: i, Type: "int32"}
+	}
+	return int32(i), nil
+}
+
+// 获取32位整数配置数据，出错时panic。
+func (c *Config) MustGetInt32(key string) int32 {
+	ret, err := c.GetInt32E(key)
+	if err != nil {
+		panic(err)
+	}
+	return ret
 }
 
-// 获取32位整数配置数据。
-func GetInt32(key string, def ...int64) (ret int32) {
-	return int32(tree.GetInt64(key, def...))
+// 获取整数配置数据，超出范围时返回默认值。
+func (c *Config) GetInt(key string, def ...int64) (ret int) {
+	ret, err := c.GetIntE(key, def...)
+	if err != nil && len(def) > 0 {
+		return int(def[0])
+	}
+	return ret
 }
 
-// 获取整数配置数据。
-func GetInt(key string, def ...int64) (ret int) {
-	return int(tree.GetInt64(key, def...))
+// 获取整数配置数据，配置项不存在且未指定默认值，类型不符或超出范围时返回错误。
+func (c *Config) GetIntE(key string, def ...int64) (ret int, err error) {
+	i, err := c.GetInt64E(key, def...)
+	if err != nil {
+		return 0, err
+	}
+	if int64(int(i)) != i {
+		return 0, &ErrOverflow{Key: key, Value: i, Type: "int"}
+	}
+	return int(i), nil
+}
+
+// 获取整数配置数据，出错时panic。
+func (c *Config) MustGetInt(key string) int {
+	ret, err := c.GetIntE(key)
+	if err != nil {
+		panic(err)
+	}
+	return ret
 }
 
 // 获取64位浮点数配置数据。
-func GetFloat64(key string, def ...float64) (ret float64) {
-	return tree.GetFloat64(key, def...)
+func (c *Config) GetFloat64(key string, def ...float64) (ret float64) {
+	ret, err := c.GetFloat64E(key, def...)
+	if err != nil && len(def) > 0 {
+		return def[0]
+	}
+	return ret
 }
 
-// 获取通用配置项，需要再次转换。
-func Get(key string) interface{} {
-	return tree.Get(key)
+// 获取64位浮点数配置数据，配置项不存在且未指定默认值，或类型不符时返回错误。
+// 整数配置项自动转换为浮点数。
+func (c *Config) GetFloat64E(key string, def ...float64) (ret float64, err error) {
+	v, env, err := c.find(key, len(def) > 0)
+	if err == errUseDef {
+		return def[0], nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if env {
+		f, err := strconv.ParseFloat(v.(string), 64)
+		if err != nil {
+			return 0, typeMismatch(key, "float64", v)
+		}
+		return f, nil
+	}
+	switch f := v.(type) {
+	case float64:
+		return f, nil
+	case int64:
+		return float64(f), nil
+	}
+	return 0, typeMismatch(key, "float64", v)
+}
+
+// 获取64位浮点数配置数据，出错时panic。
+func (c *Config) MustGetFloat64(key string) float64 {
+	ret, err := c.GetFloat64E(key)
+	if err != nil {
+		panic(err)
+	}
+	return ret
+}
+
+// 获取通用配置项，需要再次转换。表以map[string]interface{}形式返回，数组为[]interface{}。
+// 命令行参数或环境变量覆盖时，按配置文件中原值的类型转换，原值不存在时返回字符串。
+func (c *Config) Get(key string) interface{} {
+	v, _ := c.GetE(key)
+	return v
+}
+
+// 获取通用配置项，配置项不存在时返回错误。
+func (c *Config) GetE(key string) (interface{}, error) {
+	v, env, err := c.find(key, false)
+	if err != nil {
+		return nil, err
+	}
+	if env {
+		like, _ := c.getValue(key)
+		if cv, ok := coerce(v.(string), like); ok {
+			return cv, nil
+		}
+		return v, nil
+	}
+	return copyValue(v), nil
 }
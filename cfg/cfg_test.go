@@ -0,0 +1,38 @@
+package cfg
+
+import (
+	"testing"
+)
+
+func TestInstances(t *testing.T) {
+	dir := tempDir(t)
+	a, err := Load(writeFile(t, dir, "a.toml", "name = \"a\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Load(writeFile(t, dir, "b.toml", "name = \"b\"\nsize = 3\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.GetString("name") != "a" || b.GetString("name") != "b" {
+		t.Error("instances should not share data")
+	}
+	if a.GetInt("size", 7) != 7 || b.GetInt("size") != 3 {
+		t.Error("unexpected size")
+	}
+
+	if _, err := Load(dir + "/missing.toml"); err == nil {
+		t.Error("missing file should fail")
+	}
+
+	if New().GetString("name", "def") != "def" {
+		t.Error("unloaded config should return default")
+	}
+
+	old := Default()
+	defer SetDefault(old)
+	SetDefault(b)
+	if GetString("name") != "b" {
+		t.Error("package functions should use the default instance")
+	}
+}
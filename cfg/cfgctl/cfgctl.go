@@ -0,0 +1,461 @@
+/*
+包cfgctl，配置文件命令行工具的实现，cmd/cfgctl直接调用。
+服务可以注册自己的配置结构，构建带校验功能的工具：
+
+	func main() {
+		cfgctl.Main(map[string]interface{}{"mongo": &MongoConfig{}})
+	}
+*/
+package cfgctl
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/betterjun/pkg/cfg"
+)
+
+const usage = `usage: cfgctl <command> [flags] [args]
+
+commands:
+  get -c file... [-env prefix] [-reveal] <key>
+        print the resolved value of key, secrets redacted unless -reveal
+  dump -c file... [-env prefix] [-format toml|json]
+        print the merged config, secrets redacted
+  validate -c file... [-env prefix] [-schema file]
+        check the config against the registered schemas,
+        and against the JSON Schema in -schema file;
+        fails if there are neither
+  diff [-key-file file] <a> <b>
+        print the key-level differences between two config files
+  encrypt [-key-file file] [value]
+        encrypt value, or standard input, for use as an enc:v1: config value
+  keygen
+        print a new random base64 encoded key
+  gen [-format toml|jsonschema] [-o file] [-check]
+        generate a commented TOML template, or a JSON Schema for editors,
+        from the registered schemas, -check fails if file is not up to date
+
+-c may be repeated, later files override earlier ones.
+-profile selects a [profile.<name>] section, default from ` + cfg.ProfileEnv + `.
+The key for encrypted values is read from -key-file, or from the
+` + cfg.KeyEnv + ` or ` + cfg.KeyFileEnv + ` environment variables.
+`
+
+// 命令行工具。
+type Tool struct {
+	// 配置键 -> 配置结构体指针，validate命令按此校验，gen命令按此生成模板。
+	Schemas map[string]interface{}
+
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// 以os.Args运行工具并退出进程，schemas见Tool.Schemas。
+func Main(schemas map[string]interface{}) {
+	t := &Tool{Schemas: schemas, Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr}
+	os.Exit(t.Run(os.Args[1:]))
+}
+
+// 运行一条命令，args不含程序名。返回进程退出码：
+// 0表示成功，1表示失败或diff发现差异，2表示用法错误。
+func (t *Tool) Run(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(t.Stderr, usage)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "get":
+		err = t.get(args[1:])
+	case "dump":
+		err = t.dump(args[1:])
+	case "validate":
+		err = t.validate(args[1:])
+	case "diff":
+		err = t.diff(args[1:])
+	case "encrypt":
+		err = t.encrypt(args[1:])
+	case "keygen":
+		err = t.keygen(args[1:])
+	case "gen":
+		err = t.gen(args[1:])
+	case "help", "-h", "--help":
+		fmt.Fprint(t.Stdout, usage)
+		return 0
+	default:
+		err = usageError("unknown command " + args[0])
+	}
+
+	switch err.(type) {
+	case nil:
+		return 0
+	case usageError:
+		fmt.Fprintf(t.Stderr, "cfgctl: %v\n\n%s", err, usage)
+		return 2
+	case exitError:
+		return 1
+	}
+	fmt.Fprintf(t.Stderr, "cfgctl: %v\n", err)
+	return 1
+}
+
+// 用法错误。
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+// 已输出结果，仅需以非零状态退出，如diff发现差异。
+type exitError struct{}
+
+func (exitError) Error() string {
+	return "exit status 1"
+}
+
+// 可重复指定的文件列表参数。
+type fileList []string
+
+func (f *fileList) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *fileList) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+// 各命令共用的配置载入参数。
+type loadFlags struct {
+	files   fileList
+	env     string
+	keyFile string
+	profile string
+}
+
+func (t *Tool) newFlagSet(name string, lf *loadFlags) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(t.Stderr)
+	if lf != nil {
+		fs.Var(&lf.files, "c", "config `file`, may be repeated")
+		fs.StringVar(&lf.env, "env", "", "enable environment overrides with this `prefix`")
+		fs.StringVar(&lf.keyFile, "key-file", "", "read the key for encrypted values from `file`")
+		fs.StringVar(&lf.profile, "profile", "", "select the config `profile`, default from "+cfg.ProfileEnv)
+	}
+	return fs
+}
+
+func parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitError{}
+		}
+		return usageError(err.Error())
+	}
+	return nil
+}
+
+// 按参数载入配置。
+func (lf *loadFlags) load(c *cfg.Config) error {
+	if len(lf.files) == 0 {
+		return usageError("no config file, use -c")
+	}
+	if lf.env != "" {
+		c.EnableEnv(lf.env, "__")
+	}
+	if err := lf.setKey(c); err != nil {
+		return err
+	}
+	c.SetProfile(lf.profile)
+	return c.LoadConfig(lf.files...)
+}
+
+// 按-key-file参数设置解密密钥，未指定时由cfg从环境变量读取。
+func (lf *loadFlags) setKey(c *cfg.Config) error {
+	if lf.keyFile == "" {
+		return nil
+	}
+	key, err := cfg.ReadKeyFile(lf.keyFile)
+	if err != nil {
+		return err
+	}
+	c.SetSecretKey(key)
+	return nil
+}
+
+func (t *Tool) get(args []string) error {
+	var lf loadFlags
+	fs := t.newFlagSet("get", &lf)
+	reveal := fs.Bool("reveal", false, "print secrets instead of redacting them")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError("get takes exactly one key")
+	}
+	key := fs.Arg(0)
+
+	c := cfg.New()
+	if err := lf.load(c); err != nil {
+		return err
+	}
+	v, err := c.GetE(key)
+	if err != nil {
+		return err
+	}
+	if !*reveal {
+		v = c.Redact(key, v)
+	}
+
+	// 表和数组按TOML输出，其余值直接输出
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return cfg.EncodeTOML(t.Stdout, val)
+	case []interface{}:
+		return cfg.EncodeTOML(t.Stdout, map[string]interface{}{"value": v})
+	case time.Time:
+		_, err = fmt.Fprintln(t.Stdout, val.Format(time.RFC3339Nano))
+		return err
+	}
+	_, err = fmt.Fprintln(t.Stdout, v)
+	return err
+}
+
+func (t *Tool) dump(args []string) error {
+	var lf loadFlags
+	fs := t.newFlagSet("dump", &lf)
+	format := fs.String("format", "toml", "output `format`, toml or json")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usageError("dump takes no arguments")
+	}
+	if *format != "toml" && *format != "json" {
+		return usageError("unknown format " + *format)
+	}
+
+	c := cfg.New()
+	if err := lf.load(c); err != nil {
+		return err
+	}
+	data, err := c.Redacted()
+	if err != nil {
+		return err
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(t.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(data)
+	}
+	return cfg.EncodeTOML(t.Stdout, data)
+}
+
+func (t *Tool) validate(args []string) error {
+	var lf loadFlags
+	fs := t.newFlagSet("validate", &lf)
+	schemaFile := fs.String("schema", "", "also check the config against the JSON Schema in `file`")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usageError("validate takes no arguments")
+	}
+	if len(t.Schemas) == 0 && *schemaFile == "" {
+		return errors.New("no schemas registered, build cfgctl with cfgctl.Main(schemas) or pass -schema")
+	}
+
+	c := cfg.New()
+	for key, schema := range t.Schemas {
+		c.RegisterSchema(key, schema)
+	}
+	if *schemaFile != "" {
+		b, err := ioutil.ReadFile(*schemaFile)
+		if err != nil {
+			return err
+		}
+		if err := c.RegisterJSONSchema(b); err != nil {
+			return err
+		}
+	}
+	err := lf.load(c)
+	var ve *cfg.ErrValidation
+	if errors.As(err, &ve) {
+		for _, e := range ve.Errors {
+			fmt.Fprintln(t.Stdout, e)
+		}
+		return exitError{}
+	}
+	var se *cfg.ErrJSONSchema
+	if errors.As(err, &se) {
+		for _, v := range se.Violations {
+			fmt.Fprintln(t.Stdout, v)
+		}
+		return exitError{}
+	}
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(t.Stdout, "ok")
+	return err
+}
+
+func (t *Tool) diff(args []string) error {
+	var lf loadFlags
+	fs := t.newFlagSet("diff", nil)
+	fs.StringVar(&lf.keyFile, "key-file", "", "read the key for encrypted values from `file`")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return usageError("diff takes exactly two files")
+	}
+
+	a, b := cfg.New(), cfg.New()
+	for i, c := range []*cfg.Config{a, b} {
+		if err := lf.setKey(c); err != nil {
+			return err
+		}
+		if err := c.LoadConfig(fs.Arg(i)); err != nil {
+			return err
+		}
+	}
+	old, _ := a.GetStringMapE("")
+	new, _ := b.GetStringMapE("")
+
+	changes := b.RedactChanges(a.RedactChanges(cfg.Diff(old, new)))
+	for _, ch := range changes {
+		fmt.Fprintln(t.Stdout, ch)
+	}
+	if len(changes) > 0 {
+		return exitError{}
+	}
+	return nil
+}
+
+func (t *Tool) encrypt(args []string) error {
+	var lf loadFlags
+	fs := t.newFlagSet("encrypt", nil)
+	fs.StringVar(&lf.keyFile, "key-file", "", "read the key from `file`")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return usageError("encrypt takes at most one value")
+	}
+
+	var key []byte
+	var err error
+	if lf.keyFile != "" {
+		key, err = cfg.ReadKeyFile(lf.keyFile)
+	} else {
+		key, err = cfg.SecretKeyFromEnv()
+	}
+	if err != nil {
+		return err
+	}
+
+	value := fs.Arg(0)
+	if fs.NArg() == 0 {
+		data, err := ioutil.ReadAll(t.Stdin)
+		if err != nil {
+			return err
+		}
+		value = strings.TrimRight(string(data), "\r\n")
+	}
+	s, err := cfg.Encrypt(key, value)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(t.Stdout, s)
+	return err
+}
+
+func (t *Tool) keygen(args []string) error {
+	if len(args) != 0 {
+		return usageError("keygen takes no arguments")
+	}
+	key, err := cfg.GenerateKey()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(t.Stdout, base64.StdEncoding.EncodeToString(key))
+	return err
+}
+
+func (t *Tool) gen(args []string) error {
+	fs := t.newFlagSet("gen", nil)
+	format := fs.String("format", "toml", "output `format`, toml or jsonschema")
+	out := fs.String("o", "", "write the template to `file` instead of standard output")
+	check := fs.Bool("check", false, "check that the -o file is up to date instead of writing it")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usageError("gen takes no arguments")
+	}
+	if *check && *out == "" {
+		return usageError("-check requires -o")
+	}
+	if len(t.Schemas) == 0 {
+		return errors.New("no schemas registered, build cfgctl with cfgctl.Main(schemas)")
+	}
+
+	var buf bytes.Buffer
+	switch *format {
+	case "toml":
+		keys := make([]string, 0, len(t.Schemas))
+		for k := range t.Schemas {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for i, key := range keys {
+			b, err := cfg.GenerateSection(key, t.Schemas[key])
+			if err != nil {
+				return err
+			}
+			if i > 0 {
+				buf.WriteString("\n")
+			}
+			buf.Write(b)
+		}
+	case "jsonschema":
+		b, err := cfg.GenerateJSONSchemaSections(t.Schemas)
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+	default:
+		return usageError("unknown format " + *format)
+	}
+
+	switch {
+	case *check:
+		old, err := ioutil.ReadFile(*out)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(old, buf.Bytes()) {
+			return fmt.Errorf("%v, run: cfgctl gen -format %s -o %s", &cfg.ErrStaleTemplate{File: *out}, *format, *out)
+		}
+		return nil
+	case *out != "":
+		return ioutil.WriteFile(*out, buf.Bytes(), 0644)
+	}
+	_, err := t.Stdout.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,181 @@
+package cfgctl
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type mongoConfig struct {
+	Servers string `toml:"servers" valid:"Required"`
+	MaxLink int    `toml:"maxlink" valid:"Range(1,100)"`
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func run(t *testing.T, schemas map[string]interface{}, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	tool := &Tool{Schemas: schemas, Stdin: strings.NewReader("from stdin\n"), Stdout: &stdout, Stderr: &stderr}
+	code := tool.Run(args)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestCommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfgctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a := writeFile(t, dir, "a.toml", "[mongo]\nservers = \"db:27017\"\nmaxlink = 10\npassword = \"secret\"\n")
+	b := writeFile(t, dir, "b.toml", "[mongo]\nmaxlink = 200\n")
+
+	if code, out, _ := run(t, nil, "get", "-c", a, "-c", b, "mongo.maxlink"); code != 0 || out != "200\n" {
+		t.Errorf("get = %d %q", code, out)
+	}
+	if code, out, _ := run(t, nil, "get", "-c", a, "mongo.servers"); code != 0 || out != "db:27017\n" {
+		t.Errorf("get = %d %q", code, out)
+	}
+	if _, out, _ := run(t, nil, "get", "-c", a, "mongo.password"); out != "******\n" {
+		t.Errorf("get secret = %q", out)
+	}
+	if _, out, _ := run(t, nil, "get", "-c", a, "-reveal", "mongo.password"); out != "secret\n" {
+		t.Errorf("get -reveal = %q", out)
+	}
+	if code, _, errOut := run(t, nil, "get", "-c", a, "missing"); code != 1 || !strings.Contains(errOut, "not found") {
+		t.Errorf("get missing = %d %q", code, errOut)
+	}
+
+	code, out, _ := run(t, nil, "dump", "-c", a)
+	if code != 0 || !strings.Contains(out, "[mongo]") || !strings.Contains(out, `password = "******"`) {
+		t.Errorf("dump = %d\n%s", code, out)
+	}
+	code, out, _ = run(t, nil, "dump", "-c", a, "-format", "json")
+	var m map[string]map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &m); code != 0 || err != nil || m["mongo"]["password"] != "******" {
+		t.Errorf("dump json = %d %v\n%s", code, err, out)
+	}
+
+	// 格式有误时不载入配置文件
+	if code, _, errOut := run(t, nil, "dump", "-c", filepath.Join(dir, "missing.toml"), "-format", "yaml"); code != 2 || !strings.Contains(errOut, "unknown format") {
+		t.Errorf("dump -format yaml = %d %q", code, errOut)
+	}
+
+	schemas := map[string]interface{}{"mongo": &mongoConfig{}}
+	if code, out, _ := run(t, schemas, "validate", "-c", a); code != 0 || out != "ok\n" {
+		t.Errorf("validate = %d %q", code, out)
+	}
+	if code, out, _ := run(t, schemas, "validate", "-c", a, "-c", b); code != 1 || !strings.Contains(out, "mongo.maxlink") {
+		t.Errorf("validate = %d %q", code, out)
+	}
+	if code, _, errOut := run(t, nil, "validate", "-c", a); code == 0 || !strings.Contains(errOut, "no schemas registered") {
+		t.Errorf("validate without schemas = %d %q", code, errOut)
+	}
+	schemaFile := writeFile(t, dir, "schema.json", `{"properties": {"mongo": {"properties": {"maxlink": {"maximum": 100}}}}}`)
+	if code, out, _ := run(t, nil, "validate", "-c", a, "-c", b, "-schema", schemaFile); code != 1 || out != b+":2: mongo.maxlink: must be <= 100\n" {
+		t.Errorf("validate -schema = %d %q", code, out)
+	}
+
+	code, out, _ = run(t, nil, "diff", a, b)
+	want := "~ mongo.maxlink = 10 -> 200\n- mongo.password = \"******\"\n- mongo.servers = \"db:27017\"\n"
+	if code != 1 || out != want {
+		t.Errorf("diff = %d\n%s", code, out)
+	}
+	if code, out, _ := run(t, nil, "diff", a, a); code != 0 || out != "" {
+		t.Errorf("diff same = %d %q", code, out)
+	}
+
+	if code, _, _ := run(t, nil, "bogus"); code != 2 {
+		t.Errorf("unknown command = %d", code)
+	}
+	if code, _, _ := run(t, nil, "get", "mongo.maxlink"); code != 2 {
+		t.Errorf("get without -c = %d", code)
+	}
+}
+
+func TestEncrypt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfgctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	code, key, _ := run(t, nil, "keygen")
+	if code != 0 {
+		t.Fatalf("keygen = %d", code)
+	}
+	keyFile := writeFile(t, dir, "key", key)
+
+	code, enc, errOut := run(t, nil, "encrypt", "-key-file", keyFile, "p@ss")
+	if code != 0 || !strings.HasPrefix(enc, "enc:v1:") {
+		t.Fatalf("encrypt = %d %q %q", code, enc, errOut)
+	}
+	a := writeFile(t, dir, "a.toml", "[mongo]\ndsn = \""+strings.TrimSpace(enc)+"\"\n")
+
+	if _, out, _ := run(t, nil, "get", "-c", a, "-key-file", keyFile, "-reveal", "mongo.dsn"); out != "p@ss\n" {
+		t.Errorf("get -reveal = %q", out)
+	}
+	if _, out, _ := run(t, nil, "get", "-c", a, "-key-file", keyFile, "mongo.dsn"); out != "******\n" {
+		t.Errorf("get = %q", out)
+	}
+	if code, _, _ := run(t, nil, "get", "-c", a, "mongo.dsn"); code != 1 {
+		t.Errorf("get without key = %d", code)
+	}
+
+	_, enc, _ = run(t, nil, "encrypt", "-key-file", keyFile)
+	b := writeFile(t, dir, "b.toml", "v = \""+strings.TrimSpace(enc)+"\"\n")
+	if _, out, _ := run(t, nil, "get", "-c", b, "-key-file", keyFile, "-reveal", "v"); out != "from stdin\n" {
+		t.Errorf("encrypt from stdin = %q", out)
+	}
+}
+
+func TestGen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfgctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	schemas := map[string]interface{}{"mongo": &mongoConfig{}}
+	code, out, _ := run(t, schemas, "gen")
+	want := "[mongo]\n# valid: Required\nservers = \"\"\n# valid: Range(1,100)\nmaxlink = 0\n"
+	if code != 0 || out != want {
+		t.Errorf("gen = %d\n%s", code, out)
+	}
+
+	sample := filepath.Join(dir, "sample.toml")
+	if code, _, _ := run(t, schemas, "gen", "-o", sample); code != 0 {
+		t.Fatalf("gen -o = %d", code)
+	}
+	if code, _, errOut := run(t, schemas, "gen", "-o", sample, "-check"); code != 0 {
+		t.Errorf("gen -check = %d %q", code, errOut)
+	}
+	writeFile(t, dir, "sample.toml", "[mongo]\n")
+	if code, _, errOut := run(t, schemas, "gen", "-o", sample, "-check"); code != 1 || !strings.Contains(errOut, "out of date") {
+		t.Errorf("gen -check on stale file = %d %q", code, errOut)
+	}
+
+	code, out, _ = run(t, schemas, "gen", "-format", "jsonschema")
+	var s struct {
+		Properties map[string]struct {
+			Required []string
+		}
+	}
+	if err := json.Unmarshal([]byte(out), &s); code != 0 || err != nil || len(s.Properties["mongo"].Required) != 1 {
+		t.Errorf("gen jsonschema = %d %v\n%s", code, err, out)
+	}
+
+	if code, _, _ := run(t, nil, "gen"); code != 1 {
+		t.Errorf("gen without schemas = %d", code)
+	}
+}
@@ -0,0 +1,114 @@
+/*
+包cfgtest，为测试提供独立的配置对象。
+
+每个测试通过Load得到独享的配置对象，Set设置的值只对当前测试的配置对象有效，
+测试结束时自动恢复，因此使用t.Parallel()的测试互不影响：
+
+	func TestHandler(t *testing.T) {
+		t.Parallel()
+		c := cfgtest.Load(t, `
+	[mongo]
+	servers = "localhost:27017"
+	maxlink = 10
+	`)
+		cfgtest.Set(t, "mongo.maxlink", 20)
+		h := NewHandler(c)
+		...
+	}
+
+测试对象与测试一一对应，子测试需要调用Load，或直接使用父测试的配置对象。
+被测代码使用包级函数读取cfg.Default()时，用SetDefault替换默认配置对象，此时测试不能并行。
+*/
+package cfgtest
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/betterjun/pkg/cfg"
+)
+
+var (
+	mu      sync.Mutex
+	configs = make(map[testing.TB]*cfg.Config)
+)
+
+// 将TOML格式的content载入为当前测试独享的配置对象，载入失败时测试终止。
+// 同一测试多次调用时，Set和Config使用最后载入的配置对象。
+func Load(t testing.TB, content string) *cfg.Config {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "config.toml")
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatalf("cfgtest: %v", err)
+	}
+	c, err := cfg.Load(file)
+	if err != nil {
+		t.Fatalf("cfgtest: %v", err)
+	}
+	bind(t, c)
+	return c
+}
+
+// 返回当前测试的配置对象，未调用Load时返回空的配置对象。
+func Config(t testing.TB) *cfg.Config {
+	t.Helper()
+	mu.Lock()
+	c := configs[t]
+	mu.Unlock()
+	if c != nil {
+		return c
+	}
+
+	c = cfg.New()
+	if err := c.LoadConfig(); err != nil {
+		t.Fatalf("cfgtest: %v", err)
+	}
+	bind(t, c)
+	return c
+}
+
+// 覆盖当前测试配置对象中配置项key的值，测试结束时自动恢复，设置失败时测试终止。
+// key不存在时新建，value的类型规则见cfg.Config.Override。
+// 环境变量和命令行参数优先于覆盖值，因此Set关闭配置对象的环境变量覆盖并清除命令行参数的值，
+// 保证设置的值生效，不受运行测试时的环境影响。
+func Set(t testing.TB, key string, value interface{}) {
+	t.Helper()
+	c := Config(t)
+	c.DisableEnv()
+	c.ClearFlags()
+	restore, err := c.Override(key, value)
+	if err != nil {
+		t.Fatalf("cfgtest: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := restore(); err != nil {
+			t.Errorf("cfgtest: restore %s: %v", key, err)
+		}
+	})
+}
+
+// 将c设为默认配置对象，测试结束时恢复。默认配置对象是全局的，调用此函数的测试不能并行。
+func SetDefault(t testing.TB, c *cfg.Config) {
+	t.Helper()
+	old := cfg.Default()
+	cfg.SetDefault(c)
+	t.Cleanup(func() { cfg.SetDefault(old) })
+}
+
+func bind(t testing.TB, c *cfg.Config) {
+	mu.Lock()
+	_, ok := configs[t]
+	configs[t] = c
+	mu.Unlock()
+	if ok {
+		return
+	}
+
+	t.Cleanup(func() {
+		mu.Lock()
+		delete(configs, t)
+		mu.Unlock()
+	})
+}
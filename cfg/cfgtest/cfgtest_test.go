@@ -0,0 +1,96 @@
+package cfgtest
+
+import (
+	"flag"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/betterjun/pkg/cfg"
+)
+
+const sample = `
+[mongo]
+servers = "localhost:27017"
+maxlink = 10
+`
+
+func TestSet(t *testing.T) {
+	for i := 0; i < 4; i++ {
+		i := i
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			t.Parallel()
+			c := Load(t, sample)
+			Set(t, "mongo.maxlink", i)
+			Set(t, "cache.ttl", time.Minute)
+			for j := 0; j < 100; j++ {
+				if v := c.GetInt("mongo.maxlink"); v != i {
+					t.Fatalf("mongo.maxlink = %d, want %d", v, i)
+				}
+			}
+			if d := c.GetDuration("cache.ttl"); d != time.Minute {
+				t.Errorf("cache.ttl = %v", d)
+			}
+			if c != Config(t) {
+				t.Error("Config should return the loaded instance")
+			}
+		})
+	}
+}
+
+func TestRestore(t *testing.T) {
+	var c *cfg.Config
+	t.Run("set", func(t *testing.T) {
+		c = Load(t, sample)
+		Set(t, "mongo.maxlink", 20)
+		Set(t, "mongo.servers", "db:27017")
+	})
+	if v := c.GetInt("mongo.maxlink"); v != 10 {
+		t.Errorf("mongo.maxlink = %d after test, want 10", v)
+	}
+	if v := c.GetString("mongo.servers"); v != "localhost:27017" {
+		t.Errorf("mongo.servers = %q after test", v)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	Set(t, "name", "svc")
+	if v := Config(t).GetString("name"); v != "svc" {
+		t.Errorf("name = %q", v)
+	}
+}
+
+func TestSetDefault(t *testing.T) {
+	old := cfg.Default()
+	t.Run("swap", func(t *testing.T) {
+		SetDefault(t, Load(t, sample))
+		if v := cfg.GetInt("mongo.maxlink"); v != 10 {
+			t.Errorf("mongo.maxlink = %d", v)
+		}
+	})
+	if cfg.Default() != old {
+		t.Error("default config not restored")
+	}
+}
+
+func TestSetOverEnvAndFlags(t *testing.T) {
+	c := Load(t, sample)
+	t.Setenv("APP_MONGO__MAXLINK", "30")
+	c.EnableEnv("APP", "__")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := c.BindFlags(fs, "mongo.servers"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse([]string{"--mongo.servers=flag:27017"}); err != nil {
+		t.Fatal(err)
+	}
+
+	Set(t, "mongo.maxlink", 20)
+	Set(t, "mongo.servers", "db:27017")
+	if v := c.GetInt("mongo.maxlink"); v != 20 {
+		t.Errorf("mongo.maxlink = %d, want 20", v)
+	}
+	if v := c.GetString("mongo.servers"); v != "db:27017" {
+		t.Errorf("mongo.servers = %q", v)
+	}
+}
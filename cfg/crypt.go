@@ -0,0 +1,184 @@
+package cfg
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// 加密配置值的前缀，其后为base64编码的nonce和AES-GCM密文。
+const EncPrefix = "enc:v1:"
+
+// 未调用SetSecretKey时，依次从这两个环境变量读取解密密钥：
+// KeyEnv为base64编码的密钥，KeyFileEnv为密钥文件路径，文件内容同样为base64编码的密钥。
+const (
+	KeyEnv     = "CFG_SECRET_KEY"
+	KeyFileEnv = "CFG_SECRET_KEY_FILE"
+)
+
+var errNoKey = errors.New("no secret key, set " + KeyEnv + " or " + KeyFileEnv)
+
+// 设置解密密钥，长度为16、24或32字节，分别对应AES-128、AES-192、AES-256，下次载入时生效。
+func (c *Config) SetSecretKey(key []byte) {
+	c.mu.Lock()
+	c.secretKey = key
+	c.mu.Unlock()
+}
+
+// 返回解密密钥，未设置时从环境变量读取。
+func (c *Config) getSecretKey() ([]byte, error) {
+	c.mu.Lock()
+	key := c.secretKey
+	c.mu.Unlock()
+	if key != nil {
+		return key, nil
+	}
+	return SecretKeyFromEnv()
+}
+
+// 按KeyEnv、KeyFileEnv环境变量读取密钥。
+func SecretKeyFromEnv() ([]byte, error) {
+	if s := os.Getenv(KeyEnv); s != "" {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", KeyEnv, err)
+		}
+		return key, nil
+	}
+	if path := os.Getenv(KeyFileEnv); path != "" {
+		return ReadKeyFile(path)
+	}
+	return nil, errNoKey
+}
+
+// 读取密钥文件，文件内容为base64编码的密钥。
+func ReadKeyFile(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return key, nil
+}
+
+// 生成随机的AES-256密钥。
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// 加密配置值，返回以EncPrefix开头的字符串，可以直接写入配置文件。
+func Encrypt(key []byte, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return EncPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// 解密以EncPrefix开头的配置值。
+func Decrypt(key []byte, value string) (string, error) {
+	if !strings.HasPrefix(value, EncPrefix) {
+		return "", errors.New("missing " + EncPrefix + " prefix")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(value[len(EncPrefix):])
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("ciphertext too short")
+	}
+	n := gcm.NonceSize()
+	plain, err := gcm.Open(nil, sealed[:n], sealed[n:], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// 解密配置数据中的加密值，解密的配置键记入snap.encrypted，视为敏感配置项。
+func (c *Config) decrypt(snap *snapshot) error {
+	var key []byte
+	var walk func(path string, v interface{}) (interface{}, bool, error)
+	walk = func(path string, v interface{}) (interface{}, bool, error) {
+		switch val := v.(type) {
+		case string:
+			if !strings.HasPrefix(val, EncPrefix) {
+				return v, false, nil
+			}
+			if key == nil {
+				k, err := c.getSecretKey()
+				if err != nil {
+					return nil, false, &ErrDecrypt{Key: path, Err: err}
+				}
+				key = k
+			}
+			s, err := Decrypt(key, val)
+			if err != nil {
+				return nil, false, &ErrDecrypt{Key: path, Err: err}
+			}
+			return s, true, nil
+		case map[string]interface{}:
+			for k, sub := range val {
+				p := joinKey(path, k)
+				nv, enc, err := walk(p, sub)
+				if err != nil {
+					return nil, false, err
+				}
+				if enc {
+					val[k] = nv
+					snap.encrypted[p] = true
+				}
+			}
+		case []interface{}:
+			found := false
+			for i, sub := range val {
+				p := fmt.Sprintf("%s[%d]", path, i)
+				nv, enc, err := walk(p, sub)
+				if err != nil {
+					return nil, false, err
+				}
+				if enc && !isTable(sub) {
+					val[i] = nv
+					snap.encrypted[p] = true
+					found = true
+				}
+			}
+			// 标量数组中有加密值时，整个数组视为敏感配置项
+			return v, found, nil
+		}
+		return v, false, nil
+	}
+	_, _, err := walk("", snap.data)
+	return err
+}
@@ -0,0 +1,193 @@
+package cfg
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncrypt(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Encrypt(key, "p@ss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(s, EncPrefix) {
+		t.Errorf("Encrypt = %q", s)
+	}
+	if p, err := Decrypt(key, s); p != "p@ss" || err != nil {
+		t.Errorf("Decrypt = %q, %v", p, err)
+	}
+
+	other, _ := GenerateKey()
+	if _, err := Decrypt(other, s); err == nil {
+		t.Error("Decrypt with wrong key should fail")
+	}
+}
+
+func TestLoadEncrypted(t *testing.T) {
+	key, _ := GenerateKey()
+	pass, _ := Encrypt(key, "p@ss")
+	dsn, _ := Encrypt(key, "mongodb://root:p@ss@db")
+	dir := tempDir(t)
+	file := writeFile(t, dir, "a.toml", "[mongo]\nuser = \"root\"\npassword = \""+pass+"\"\ndsn = \""+dsn+"\"\n")
+
+	c := New()
+	err := c.LoadConfig(file)
+	var de *ErrDecrypt
+	if !errors.As(err, &de) || de.Key == "" {
+		t.Fatalf("load without key = %v", err)
+	}
+
+	c.SetSecretKey(key)
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if v := c.GetString("mongo.password"); v != "p@ss" {
+		t.Errorf("mongo.password = %q", v)
+	}
+	if !c.IsSecret("mongo.dsn") || c.IsSecret("mongo.user") {
+		t.Error("decrypted values should be secret")
+	}
+
+	data, _ := c.Redacted()
+	want := map[string]interface{}{"user": "root", "password": Redacted, "dsn": Redacted}
+	if !reflect.DeepEqual(data["mongo"], want) {
+		t.Errorf("Redacted = %v", data["mongo"])
+	}
+	if s := c.RedactText("connect mongodb://root:p@ss@db as root"); s != "connect ****** as root" {
+		t.Errorf("RedactText = %q", s)
+	}
+
+	os.Setenv(KeyEnv, base64.StdEncoding.EncodeToString(key))
+	defer os.Unsetenv(KeyEnv)
+	c2, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := c2.GetString("mongo.dsn"); v != "mongodb://root:p@ss@db" {
+		t.Errorf("mongo.dsn = %q", v)
+	}
+}
+
+func TestKeyFile(t *testing.T) {
+	key, _ := GenerateKey()
+	dir := tempDir(t)
+	path := writeFile(t, dir, "key", base64.StdEncoding.EncodeToString(key)+"\n")
+
+	os.Setenv(KeyFileEnv, path)
+	defer os.Unsetenv(KeyFileEnv)
+	got, err := SecretKeyFromEnv()
+	if err != nil || !reflect.DeepEqual(got, key) {
+		t.Errorf("SecretKeyFromEnv = %v, %v", got, err)
+	}
+}
+
+func TestMarkSecret(t *testing.T) {
+	dir := tempDir(t)
+	c, err := Load(writeFile(t, dir, "a.toml", "[mongo]\nservers = \"db\"\n[auth]\nuser = \"root\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.MarkSecret("mongo.servers", "auth")
+	data, _ := c.Redacted()
+	if data["auth"] != Redacted || data["mongo"].(map[string]interface{})["servers"] != Redacted {
+		t.Errorf("Redacted = %v", data)
+	}
+	if !c.IsSecret("auth.user") {
+		t.Error("sub-keys of a secret key should be secret")
+	}
+}
+
+func TestInterpolatedSecret(t *testing.T) {
+	key, _ := GenerateKey()
+	pass, _ := Encrypt(key, "hunter2")
+	dir := tempDir(t)
+	content := "[db]\nuser = \"u\"\npass = \"" + pass + "\"\nurl = \"mongodb://u:${db.pass}@h\"\nlogin = \"${db.user}\"\n" +
+		"hosts = [\"${db.pass}\", \"x\"]\n[copy]\nauth = \"${db}\"\n"
+	file := writeFile(t, dir, "a.toml", content)
+
+	c := New()
+	c.SetSecretKey(key)
+	if err := c.LoadConfig(file); err != nil {
+		t.Fatal(err)
+	}
+	if v := c.GetString("db.url"); v != "mongodb://u:hunter2@h" {
+		t.Errorf("db.url = %q", v)
+	}
+	for _, k := range []string{"db.url", "db.hosts", "copy.auth.pass", "copy.auth.url"} {
+		if !c.IsSecret(k) {
+			t.Errorf("%s should be secret", k)
+		}
+	}
+	if c.IsSecret("db.login") || c.IsSecret("copy.auth.user") {
+		t.Error("values without secrets should not be secret")
+	}
+	data, _ := c.Redacted()
+	if s := fmt.Sprint(data); strings.Contains(s, "hunter2") {
+		t.Errorf("Redacted leaks the secret: %s", s)
+	}
+
+	var lines []string
+	c.SetChangeLog(func(format string, v ...interface{}) { lines = append(lines, fmt.Sprintf(format, v...)) })
+	pass2, _ := Encrypt(key, "hunter3")
+	writeFile(t, dir, "a.toml", strings.Replace(content, pass, pass2, 1))
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if s := fmt.Sprint(lines, c.History()); len(lines) == 0 || strings.Contains(s, "hunter") {
+		t.Errorf("change log leaks the secret: %s", s)
+	}
+}
+
+func TestEncryptedNotInterpolated(t *testing.T) {
+	key, _ := GenerateKey()
+	a, _ := Encrypt(key, "s3cr${et}pw")
+	b, _ := Encrypt(key, "pa$${x")
+	dir := tempDir(t)
+	file := writeFile(t, dir, "a.toml", "a = \""+a+"\"\nlist = [\""+b+"\", \"${a}\"]\n")
+
+	c := New()
+	c.SetSecretKey(key)
+	if err := c.LoadConfig(file); err != nil {
+		t.Fatal(err)
+	}
+	if v := c.GetString("a"); v != "s3cr${et}pw" {
+		t.Errorf("a = %q", v)
+	}
+	if v := c.GetStringSlice("list"); !reflect.DeepEqual(v, []string{"pa$${x", "s3cr${et}pw"}) {
+		t.Errorf("list = %q", v)
+	}
+}
+
+func TestSecretNames(t *testing.T) {
+	c := New()
+	for _, k := range []string{"db.password", "db.dbPassword", "DBPassword", "access_token", "client-secret", "api_key", "apiKey", "apikey", "key", "tls.private_key"} {
+		if !c.IsSecret(k) {
+			t.Errorf("%s should be secret", k)
+		}
+	}
+	for _, k := range []string{"max_tokens", "private_ip", "keys", "keyspace", "monkey", "key_count"} {
+		if c.IsSecret(k) {
+			t.Errorf("%s should not be secret", k)
+		}
+	}
+}
+
+func TestRedactTextValues(t *testing.T) {
+	dir := tempDir(t)
+	c, err := Load(writeFile(t, dir, "a.toml", "pin_secret = 1\nuse_token = true\nshort_password = \"ab\"\npassword = \"hunter2\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := c.RedactText("retry 1 of 3, ok = true, ab, hunter2"); s != "retry 1 of 3, ok = true, ab, ******" {
+		t.Errorf("RedactText = %q", s)
+	}
+}
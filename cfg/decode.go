@@ -0,0 +1,339 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/betterjun/go-toml"
+)
+
+// 将key对应的配置子树解码到target中，target必须为非空指针。
+// 结构体字段按toml标签匹配，没有标签时按字段名（忽略大小写）匹配。
+func (c *Config) Unmarshal(key string, target interface{}) error {
+	return c.unmarshal(c.getSnapshot(), key, target)
+}
+
+// 将整个配置解码到target中，target必须为非空指针。
+func (c *Config) UnmarshalAll(target interface{}) error {
+	return c.unmarshal(c.getSnapshot(), "", target)
+}
+
+// 将快照中key对应的配置子树解码到target中，key为空串时解码整个配置。
+func (c *Config) unmarshal(snap *snapshot, key string, target interface{}) error {
+	if snap == nil {
+		return ErrNotLoaded
+	}
+	v, ok := lookup(snap.data, key)
+	if !ok {
+		return &ErrKeyNotFound{Key: key}
+	}
+	return decodeValue(key, c.overlay(key, v), target)
+}
+
+func decodeValue(path string, src interface{}, target interface{}) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("cfg: unmarshal target must be a non-nil pointer")
+	}
+	return decode(path, src, rv.Elem())
+}
+
+// 将toml库的树结构转换为map/slice组成的通用结构。
+func normalize(v interface{}) interface{} {
+	switch val := v.(type) {
+	case *toml.TomlTree:
+		m := make(map[string]interface{})
+		for _, k := range val.Keys() {
+			m[k] = normalize(val.Get(k))
+		}
+		return m
+	case []*toml.TomlTree:
+		s := make([]interface{}, len(val))
+		for i, t := range val {
+			s[i] = normalize(t)
+		}
+		return s
+	case []interface{}:
+		s := make([]interface{}, len(val))
+		for i, e := range val {
+			s[i] = normalize(e)
+		}
+		return s
+	}
+	return v
+}
+
+// 深度复制map/slice组成的通用结构，避免调用者修改配置快照。
+func copyValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, e := range val {
+			m[k] = copyValue(e)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(val))
+		for i, e := range val {
+			s[i] = copyValue(e)
+		}
+		return s
+	}
+	return v
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+func decode(path string, src interface{}, dst reflect.Value) error {
+	// 空值（如代码中设置的nil）解码为零值
+	if src == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	if dst.Kind() == reflect.Ptr {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return decode(path, src, dst.Elem())
+	}
+
+	// 复制表和数组，修改解码结果不影响配置数据
+	if dst.Kind() == reflect.Interface && dst.NumMethod() == 0 {
+		dst.Set(reflect.ValueOf(copyValue(src)))
+		return nil
+	}
+
+	if dst.Type() == timeType {
+		t, ok := src.(time.Time)
+		if !ok {
+			return mismatch(path, src, dst)
+		}
+		dst.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	// 时长支持"1m30s"形式的字符串，整数表示秒数。
+	if dst.Type() == durationType {
+		d, ok := toDuration(src)
+		if !ok {
+			return mismatch(path, src, dst)
+		}
+		dst.SetInt(int64(d))
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Struct:
+		m, ok := src.(map[string]interface{})
+		if !ok {
+			return mismatch(path, src, dst)
+		}
+		return decodeStruct(path, m, dst)
+	case reflect.Map:
+		m, ok := src.(map[string]interface{})
+		if !ok || dst.Type().Key().Kind() != reflect.String {
+			return mismatch(path, src, dst)
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMap(dst.Type()))
+		}
+		for k, v := range m {
+			ev := reflect.New(dst.Type().Elem()).Elem()
+			if err := decode(joinKey(path, k), v, ev); err != nil {
+				return err
+			}
+			dst.SetMapIndex(reflect.ValueOf(k).Convert(dst.Type().Key()), ev)
+		}
+		return nil
+	case reflect.Slice:
+		s, ok := src.([]interface{})
+		if !ok {
+			return mismatch(path, src, dst)
+		}
+		sv := reflect.MakeSlice(dst.Type(), len(s), len(s))
+		for i, e := range s {
+			if err := decode(fmt.Sprintf("%s[%d]", path, i), e, sv.Index(i)); err != nil {
+				return err
+			}
+		}
+		dst.Set(sv)
+		return nil
+	case reflect.Array:
+		s, ok := src.([]interface{})
+		if !ok || len(s) > dst.Len() {
+			return mismatch(path, src, dst)
+		}
+		for i, e := range s {
+			if err := decode(fmt.Sprintf("%s[%d]", path, i), e, dst.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.String:
+		s, ok := src.(string)
+		if !ok {
+			return mismatch(path, src, dst)
+		}
+		dst.SetString(s)
+		return nil
+	case reflect.Bool:
+		b, ok := src.(bool)
+		if !ok {
+			return mismatch(path, src, dst)
+		}
+		dst.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := src.(int64)
+		if !ok || dst.OverflowInt(i) {
+			return mismatch(path, src, dst)
+		}
+		dst.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := src.(int64)
+		if !ok || i < 0 || dst.OverflowUint(uint64(i)) {
+			return mismatch(path, src, dst)
+		}
+		dst.SetUint(uint64(i))
+		return nil
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		switch n := src.(type) {
+		case float64:
+			f = n
+		case int64:
+			f = float64(n)
+		default:
+			return mismatch(path, src, dst)
+		}
+		if dst.Kind() == reflect.Float32 && math.Abs(f) > math.MaxFloat32 {
+			return mismatch(path, src, dst)
+		}
+		dst.SetFloat(f)
+		return nil
+	}
+
+	return mismatch(path, src, dst)
+}
+
+func decodeStruct(path string, m map[string]interface{}, dst reflect.Value) error {
+	return eachField(dst, true, func(name string, f reflect.StructField, fv reflect.Value) error {
+		k, v, found := lookupField(m, name, f.Name)
+		if !found {
+			return nil
+		}
+		return decode(joinKey(path, k), v, fv)
+	})
+}
+
+// 依次对v中对应配置项的字段调用fn，name为配置键名，跳过忽略的字段和未导出的字段。
+// 未指定标签的匿名结构体字段，其成员与外层共用同一张表，展开后遍历。
+// 匿名结构体指针为nil时，alloc为true则分配新的结构体（解码时使用），否则遍历其零值。
+func eachField(v reflect.Value, alloc bool, fn func(name string, f reflect.StructField, fv reflect.Value) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		name, ok := fieldKey(f)
+		if !ok {
+			continue
+		}
+		fv := v.Field(i)
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() != reflect.Struct {
+				continue
+			}
+			if fv.Kind() == reflect.Ptr {
+				if alloc {
+					if !fv.CanSet() {
+						continue
+					}
+					if fv.IsNil() {
+						fv.Set(reflect.New(ft))
+					}
+				}
+				if fv.IsNil() {
+					fv = reflect.Zero(ft)
+				} else {
+					fv = fv.Elem()
+				}
+			}
+			if err := eachField(fv, alloc, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+
+		if err := fn(name, f, fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 返回字段对应的配置键名，第二个返回值为false时表示忽略该字段。
+// 匿名字段未指定标签时返回空键名。
+func fieldKey(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("toml")
+	if tag == "-" {
+		return "", false
+	}
+	if idx := strings.Index(tag, ","); idx >= 0 {
+		tag = tag[:idx]
+	}
+	if tag != "" {
+		return tag, true
+	}
+	if f.Anonymous {
+		return "", true
+	}
+	return f.Name, true
+}
+
+func lookupField(m map[string]interface{}, name, fieldName string) (string, interface{}, bool) {
+	if v, ok := m[name]; ok {
+		return name, v, true
+	}
+	if name != fieldName {
+		return "", nil, false
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, name) {
+			return k, v, true
+		}
+	}
+	return "", nil, false
+}
+
+func joinKey(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func mismatch(path string, src interface{}, dst reflect.Value) error {
+	if path == "" {
+		path = "<root>"
+	}
+	return &ErrTypeMismatch{Key: path, Expected: dst.Type().String(), Actual: typeName(src)}
+}
@@ -0,0 +1,172 @@
+package cfg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 将内容写入临时目录中的文件，返回文件路径。
+func writeFile(t *testing.T, dir, name, content string) string {
+	file := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "cfg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+const decodeToml = `
+name = "svc"
+
+[mongo]
+servers = "127.0.0.1:27017"
+maxlink = 10
+timeout = 5
+ratio = 0.5
+
+[mongo.auth]
+user = "admin"
+
+[[backends]]
+host = "a"
+port = 80
+
+[[backends]]
+host = "b"
+port = 81
+
+[labels]
+zone = "cn"
+role = "api"
+
+[tags]
+list = ["x", "y"]
+`
+
+type auth struct {
+	User string `toml:"user"`
+}
+
+type base struct {
+	Servers string `toml:"servers"`
+}
+
+type mongoSettings struct {
+	base
+	MaxLink int     `toml:"maxlink"`
+	Timeout int8    `toml:"timeout"`
+	Ratio   float32 `toml:"ratio"`
+	Auth    *auth   `toml:"auth"`
+	Ignored string  `toml:"-"`
+}
+
+type settings struct {
+	Name     string
+	Mongo    mongoSettings `toml:"mongo"`
+	Backends []struct {
+		Host string `toml:"host"`
+		Port uint16 `toml:"port"`
+	} `toml:"backends"`
+	Labels map[string]string `toml:"labels"`
+	Tags   struct {
+		List []string `toml:"list"`
+	} `toml:"tags"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	dir := tempDir(t)
+	if err := LoadConfig(writeFile(t, dir, "a.toml", decodeToml)); err != nil {
+		t.Fatal(err)
+	}
+
+	var s settings
+	if err := UnmarshalAll(&s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "svc" || s.Mongo.Servers != "127.0.0.1:27017" || s.Mongo.MaxLink != 10 ||
+		s.Mongo.Timeout != 5 || s.Mongo.Ratio != 0.5 || s.Mongo.Auth == nil || s.Mongo.Auth.User != "admin" {
+		t.Errorf("unexpected settings: %+v", s)
+	}
+	if len(s.Backends) != 2 || s.Backends[1].Host != "b" || s.Backends[1].Port != 81 {
+		t.Errorf("unexpected backends: %+v", s.Backends)
+	}
+	if s.Labels["zone"] != "cn" || len(s.Tags.List) != 2 {
+		t.Errorf("unexpected labels or tags: %+v %+v", s.Labels, s.Tags)
+	}
+
+	var m mongoSettings
+	if err := Unmarshal("mongo", &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.MaxLink != 10 {
+		t.Errorf("maxlink = %d", m.MaxLink)
+	}
+
+	if err := Unmarshal("missing", &m); err == nil {
+		t.Error("missing key should fail")
+	}
+
+	var raw struct {
+		Mongo interface{} `toml:"mongo"`
+	}
+	if err := UnmarshalAll(&raw); err != nil {
+		t.Fatal(err)
+	}
+	raw.Mongo.(map[string]interface{})["maxlink"] = int64(99)
+	if v := GetInt("mongo.maxlink"); v != 10 {
+		t.Errorf("changing the decoded value changed the config, mongo.maxlink = %d", v)
+	}
+}
+
+func TestUnmarshalMismatch(t *testing.T) {
+	dir := tempDir(t)
+	if err := LoadConfig(writeFile(t, dir, "a.toml", decodeToml)); err != nil {
+		t.Fatal(err)
+	}
+
+	var bad struct {
+		Backends []struct {
+			Port string `toml:"port"`
+		} `toml:"backends"`
+	}
+	err := UnmarshalAll(&bad)
+	if err == nil || !strings.Contains(err.Error(), "backends[0].port") {
+		t.Errorf("error should carry key path, got %v", err)
+	}
+
+	var small struct {
+		Mongo struct {
+			MaxLink uint8 `toml:"maxlink"`
+		} `toml:"mongo"`
+	}
+	if err := UnmarshalAll(&small); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDecodeNil(t *testing.T) {
+	s := struct {
+		Name string
+		Auth *auth
+		Raw  interface{}
+	}{"x", &auth{}, 1}
+	src := map[string]interface{}{"Name": nil, "Auth": nil, "Raw": nil}
+	if err := decode("", src, reflect.ValueOf(&s).Elem()); err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "" || s.Auth != nil || s.Raw != nil {
+		t.Errorf("nil should decode to zero values, got %+v", s)
+	}
+}
@@ -0,0 +1,425 @@
+package cfg
+
+import (
+	"flag"
+	"sync/atomic"
+	"time"
+)
+
+// 默认配置对象，保存*Config。
+var std atomic.Value
+
+func init() {
+	std.Store(New())
+}
+
+// 返回默认配置对象，包级函数均作用于该对象。
+func Default() *Config {
+	return std.Load().(*Config)
+}
+
+// 替换默认配置对象，c不能为nil。
+func SetDefault(c *Config) {
+	std.Store(c)
+}
+
+// 载入配置文件。可以按顺序指定多个文件，如base.toml、prod.toml、local.toml，
+// 各文件深度合并，后面的文件覆盖前面文件中的同名配置项。
+func LoadConfig(files ...string) (err error) {
+	return Default().LoadConfig(files...)
+}
+
+// 重新读取当前配置文件。文件有误时返回错误，原配置保持不变。
+func Reload() error {
+	return Default().Reload()
+}
+
+// 以interval为间隔轮询配置文件和远程配置源，任一变化时重新载入。
+func Watch(interval time.Duration, onError func(error)) (stop func()) {
+	return Default().Watch(interval, onError)
+}
+
+// 添加远程配置源，见Config.AddRemote。
+func AddRemote(src RemoteSource) error {
+	return Default().AddRemote(src)
+}
+
+// 拉取所有远程配置源，有变化时重新载入配置。
+func Refresh() error {
+	return Default().Refresh()
+}
+
+// 在内存中覆盖配置项的值，见Config.Override。
+func Override(key string, value interface{}) (restore func() error, err error) {
+	return Default().Override(key, value)
+}
+
+// 设置配置项的值，见Config.Set。
+func Set(key string, value interface{}) error {
+	return Default().Set(key, value)
+}
+
+// 将Set设置的配置项写入配置文件，见Config.Save。
+func Save(path string) error {
+	return Default().Save(path)
+}
+
+// 返回最近的配置变化记录，见Config.History。
+func History() []Revision {
+	return Default().History()
+}
+
+// 设置记录配置变化的日志函数，见Config.SetChangeLog。
+func SetChangeLog(fn func(format string, v ...interface{})) {
+	Default().SetChangeLog(fn)
+}
+
+// 设置保留的配置变化记录数，见Config.SetHistorySize。
+func SetHistorySize(n int) {
+	Default().SetHistorySize(n)
+}
+
+// 注册配置变化回调，见Config.OnChange。
+func OnChange(prefix string, fn func(old, new interface{})) (cancel func()) {
+	return Default().OnChange(prefix, fn)
+}
+
+// 返回默认配置对象中字符串配置项的句柄，见Config.StringVar。
+func StringVar(key string, def string) *StringValue {
+	return Default().StringVar(key, def)
+}
+
+// 返回默认配置对象中整数配置项的句柄，见Config.IntVar。
+func IntVar(key string, def int) *IntValue {
+	return Default().IntVar(key, def)
+}
+
+// 返回默认配置对象中64位整数配置项的句柄，见Config.Int64Var。
+func Int64Var(key string, def int64) *Int64Value {
+	return Default().Int64Var(key, def)
+}
+
+// 返回默认配置对象中64位浮点数配置项的句柄，见Config.Float64Var。
+func Float64Var(key string, def float64) *Float64Value {
+	return Default().Float64Var(key, def)
+}
+
+// 返回默认配置对象中布尔配置项的句柄，见Config.BoolVar。
+func BoolVar(key string, def bool) *BoolValue {
+	return Default().BoolVar(key, def)
+}
+
+// 返回默认配置对象中时长配置项的句柄，见Config.DurationVar。
+func DurationVar(key string, def time.Duration) *DurationValue {
+	return Default().DurationVar(key, def)
+}
+
+// 返回默认配置对象中字符串数组配置项的句柄，见Config.StringSliceVar。
+func StringSliceVar(key string, def []string) *StringSliceValue {
+	return Default().StringSliceVar(key, def)
+}
+
+// 获取字符串配置数据。
+func GetString(key string, def ...string) (ret string) {
+	return Default().GetString(key, def...)
+}
+
+// 获取字符串配置数据，配置项不存在且未指定默认值，或类型不符时返回错误。
+func GetStringE(key string, def ...string) (ret string, err error) {
+	return Default().GetStringE(key, def...)
+}
+
+// 获取字符串配置数据，出错时panic。
+func MustGetString(key string) string {
+	return Default().MustGetString(key)
+}
+
+// 获取64位整数配置数据。
+func GetInt64(key string, def ...int64) (ret int64) {
+	return Default().GetInt64(key, def...)
+}
+
+// 获取64位整数配置数据，配置项不存在且未指定默认值，或类型不符时返回错误。
+func GetInt64E(key string, def ...int64) (ret int64, err error) {
+	return Default().GetInt64E(key, def...)
+}
+
+// 获取64位整数配置数据，出错时panic。
+func MustGetInt64(key string) int64 {
+	return Default().MustGetInt64(key)
+}
+
+// 获取32位整数配置数据，超出范围时返回默认值。
+func GetInt32(key string, def ...int64) (ret int32) {
+	return Default().GetInt32(key, def...)
+}
+
+// 获取32位整数配置数据，配置项不存在且未指定默认值，类型不符或超出范围时返回错误。
+func GetInt32E(key string, def ...int64) (ret int32, err error) {
+	return Default().GetInt32E(key, def...)
+}
+
+// 获取32位整数配置数据，出错时panic。
+func MustGetInt32(key string) int32 {
+	return Default().MustGetInt32(key)
+}
+
+// 获取整数配置数据，超出范围时返回默认值。
+func GetInt(key string, def ...int64) (ret int) {
+	return Default().GetInt(key, def...)
+}
+
+// 获取整数配置数据，配置项不存在且未指定默认值，类型不符或超出范围时返回错误。
+func GetIntE(key string, def ...int64) (ret int, err error) {
+	return Default().GetIntE(key, def...)
+}
+
+// 获取整数配置数据，出错时panic。
+func MustGetInt(key string) int {
+	return Default().MustGetInt(key)
+}
+
+// 获取64位浮点数配置数据。
+func GetFloat64(key string, def ...float64) (ret float64) {
+	return Default().GetFloat64(key, def...)
+}
+
+// 获取64位浮点数配置数据，配置项不存在且未指定默认值，或类型不符时返回错误。
+func GetFloat64E(key string, def ...float64) (ret float64, err error) {
+	return Default().GetFloat64E(key, def...)
+}
+
+// 获取64位浮点数配置数据，出错时panic。
+func MustGetFloat64(key string) float64 {
+	return Default().MustGetFloat64(key)
+}
+
+// 获取布尔配置数据。
+func GetBool(key string, def ...bool) (ret bool) {
+	return Default().GetBool(key, def...)
+}
+
+// 获取布尔配置数据，出错时返回错误。
+func GetBoolE(key string, def ...bool) (ret bool, err error) {
+	return Default().GetBoolE(key, def...)
+}
+
+// 获取布尔配置数据，出错时panic。
+func MustGetBool(key string) bool {
+	return Default().MustGetBool(key)
+}
+
+// 获取时长配置数据。字符串按time.ParseDuration解析，整数及整数字符串表示秒数。
+func GetDuration(key string, def ...time.Duration) (ret time.Duration) {
+	return Default().GetDuration(key, def...)
+}
+
+// 获取时长配置数据，出错时返回错误。
+func GetDurationE(key string, def ...time.Duration) (ret time.Duration, err error) {
+	return Default().GetDurationE(key, def...)
+}
+
+// 获取时长配置数据，出错时panic。
+func MustGetDuration(key string) time.Duration {
+	return Default().MustGetDuration(key)
+}
+
+// 获取时间配置数据。
+func GetTime(key string, def ...time.Time) (ret time.Time) {
+	return Default().GetTime(key, def...)
+}
+
+// 获取时间配置数据，出错时返回错误。
+func GetTimeE(key string, def ...time.Time) (ret time.Time, err error) {
+	return Default().GetTimeE(key, def...)
+}
+
+// 获取时间配置数据，出错时panic。
+func MustGetTime(key string) time.Time {
+	return Default().MustGetTime(key)
+}
+
+// 获取字符串数组配置数据。
+func GetStringSlice(key string, def ...string) (ret []string) {
+	return Default().GetStringSlice(key, def...)
+}
+
+// 获取字符串数组配置数据，出错时返回错误。
+func GetStringSliceE(key string, def ...string) (ret []string, err error) {
+	return Default().GetStringSliceE(key, def...)
+}
+
+// 获取字符串数组配置数据，出错时panic。
+func MustGetStringSlice(key string) []string {
+	return Default().MustGetStringSlice(key)
+}
+
+// 获取整数数组配置数据。
+func GetIntSlice(key string, def ...int) (ret []int) {
+	return Default().GetIntSlice(key, def...)
+}
+
+// 获取整数数组配置数据，出错时返回错误。
+func GetIntSliceE(key string, def ...int) (ret []int, err error) {
+	return Default().GetIntSliceE(key, def...)
+}
+
+// 获取整数数组配置数据，出错时panic。
+func MustGetIntSlice(key string) []int {
+	return Default().MustGetIntSlice(key)
+}
+
+// 获取表配置数据。
+func GetStringMap(key string, def ...map[string]interface{}) (ret map[string]interface{}) {
+	return Default().GetStringMap(key, def...)
+}
+
+// 获取表配置数据，出错时返回错误。
+func GetStringMapE(key string, def ...map[string]interface{}) (ret map[string]interface{}, err error) {
+	return Default().GetStringMapE(key, def...)
+}
+
+// 获取表配置数据，出错时panic。
+func MustGetStringMap(key string) map[string]interface{} {
+	return Default().MustGetStringMap(key)
+}
+
+// 获取字节数配置数据。字符串支持"64MB"形式的单位。
+func GetSize(key string, def ...int64) (ret int64) {
+	return Default().GetSize(key, def...)
+}
+
+// 获取字节数配置数据，出错时返回错误。
+func GetSizeE(key string, def ...int64) (ret int64, err error) {
+	return Default().GetSizeE(key, def...)
+}
+
+// 获取字节数配置数据，出错时panic。
+func MustGetSize(key string) int64 {
+	return Default().MustGetSize(key)
+}
+
+// 获取通用配置项，需要再次转换。
+func Get(key string) interface{} {
+	return Default().Get(key)
+}
+
+// 获取通用配置项，配置项不存在时返回错误。
+func GetE(key string) (interface{}, error) {
+	return Default().GetE(key)
+}
+
+// 将key对应的配置子树解码到target中，见Config.Unmarshal。
+func Unmarshal(key string, target interface{}) error {
+	return Default().Unmarshal(key, target)
+}
+
+// 将整个配置解码到target中，target必须为非空指针。
+func UnmarshalAll(target interface{}) error {
+	return Default().UnmarshalAll(target)
+}
+
+// 解码key对应的配置子树并按valid标签校验，见Config.UnmarshalAndValidate。
+func UnmarshalAndValidate(key string, target interface{}) error {
+	return Default().UnmarshalAndValidate(key, target)
+}
+
+// 注册配置结构，载入时校验，见Config.RegisterSchema。
+func RegisterSchema(key string, schema interface{}) {
+	Default().RegisterSchema(key, schema)
+}
+
+// 注册JSON Schema文档，载入时校验，见Config.RegisterJSONSchema。
+func RegisterJSONSchema(schema []byte) error {
+	return Default().RegisterJSONSchema(schema)
+}
+
+// 按JSON Schema文档校验当前生效的配置，见Config.ValidateJSONSchema。
+func ValidateJSONSchema(schema []byte) error {
+	return Default().ValidateJSONSchema(schema)
+}
+
+// 返回配置项生效值的来源，配置项不存在时第二个返回值为false。
+func Source(key string) (Location, bool) {
+	return Default().Source(key)
+}
+
+// 设置多个配置文件合并时数组的合并策略，下次载入时生效。
+func SetArrayPolicy(policy int) {
+	Default().SetArrayPolicy(policy)
+}
+
+// 指定所有配置文件使用的解析器，为nil时按扩展名选择，下次载入时生效。
+func SetLoader(l Loader) {
+	Default().SetLoader(l)
+}
+
+// 设置解密密钥，见Config.SetSecretKey。
+func SetSecretKey(key []byte) {
+	Default().SetSecretKey(key)
+}
+
+// 将配置键标记为敏感配置项，见Config.MarkSecret。
+func MarkSecret(keys ...string) {
+	Default().MarkSecret(keys...)
+}
+
+// 将文本中出现的敏感配置项的值替换为Redacted。
+func RedactText(s string) string {
+	return Default().RedactText(s)
+}
+
+// 选择配置方案，见Config.SetProfile。
+func SetProfile(name string) {
+	Default().SetProfile(name)
+}
+
+// 返回生效的配置方案，未选择时返回空串。
+func ActiveProfile() string {
+	return Default().ActiveProfile()
+}
+
+// 声明配置项及其默认值，见Config.Declare。
+func Declare(key string, def ...interface{}) {
+	Default().Declare(key, def...)
+}
+
+// 设置严格模式，下次载入时生效。
+func SetStrict(strict bool) {
+	Default().SetStrict(strict)
+}
+
+// 检查未提供默认值的声明配置项是否都存在。
+func Check() error {
+	return Default().Check()
+}
+
+// 启用环境变量覆盖，见Config.EnableEnv。
+func EnableEnv(prefix, sep string) {
+	Default().EnableEnv(prefix, sep)
+}
+
+// 关闭环境变量覆盖。
+func DisableEnv() {
+	Default().DisableEnv()
+}
+
+// 将配置项绑定为fs中的命令行参数，见Config.BindFlags。
+func BindFlags(fs *flag.FlagSet, keys ...string) error {
+	return Default().BindFlags(fs, keys...)
+}
+
+// 清除命令行参数设置的值，见Config.ClearFlags。
+func ClearFlags() {
+	Default().ClearFlags()
+}
+
+// 返回配置键对应的环境变量名，未启用环境变量覆盖时返回空串。
+func EnvName(key string) string {
+	return Default().EnvName(key)
+}
+
+// 返回取值来自环境变量的配置键。
+func EnvKeys() []string {
+	return Default().EnvKeys()
+}
@@ -0,0 +1,87 @@
+package cfg
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// 配置项变化类型。
+const (
+	ChangeAdded    = iota // 新增配置项
+	ChangeRemoved         // 删除配置项
+	ChangeModified        // 修改配置项
+)
+
+// 一个配置项的变化。
+type Change struct {
+	Key  string      // 配置键路径，表数组中的项形如"backends[0].host"
+	Kind int         // 变化类型，ChangeAdded、ChangeRemoved或ChangeModified
+	Old  interface{} // 原值，新增时为nil
+	New  interface{} // 新值，删除时为nil
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangeAdded:
+		return fmt.Sprintf("+ %s = %s", c.Key, formatChange(c.New))
+	case ChangeRemoved:
+		return fmt.Sprintf("- %s = %s", c.Key, formatChange(c.Old))
+	}
+	return fmt.Sprintf("~ %s = %s -> %s", c.Key, formatChange(c.Old), formatChange(c.New))
+}
+
+func formatChange(v interface{}) string {
+	if s, err := tomlValue(v); err == nil {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+// 比较两份配置数据，返回叶子配置项的变化，按配置键排序。
+// 表逐层展开，表数组按下标展开，其他数组作为整体比较。
+func Diff(old, new map[string]interface{}) []Change {
+	o, n := make(map[string]interface{}), make(map[string]interface{})
+	flatten(o, "", old)
+	flatten(n, "", new)
+
+	var changes []Change
+	for k, ov := range o {
+		nv, ok := n[k]
+		if !ok {
+			changes = append(changes, Change{Key: k, Kind: ChangeRemoved, Old: ov})
+		} else if !reflect.DeepEqual(ov, nv) {
+			changes = append(changes, Change{Key: k, Kind: ChangeModified, Old: ov, New: nv})
+		}
+	}
+	for k, nv := range n {
+		if _, ok := o[k]; !ok {
+			changes = append(changes, Change{Key: k, Kind: ChangeAdded, New: nv})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// 将配置数据展开为叶子配置键 -> 值。
+func flatten(dst map[string]interface{}, path string, v interface{}) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if len(val) == 0 && path != "" {
+			dst[path] = val
+		}
+		for k, sub := range val {
+			flatten(dst, joinKey(path, k), sub)
+		}
+	case []interface{}:
+		if !isTableArray(val) {
+			dst[path] = val
+			return
+		}
+		for i, sub := range val {
+			flatten(dst, fmt.Sprintf("%s[%d]", path, i), sub)
+		}
+	default:
+		dst[path] = v
+	}
+}
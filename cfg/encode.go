@@ -0,0 +1,199 @@
+package cfg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 将配置数据编码为TOML写入w，同一层级按键名排序，先输出普通配置项，再输出表和表数组。
+func EncodeTOML(w io.Writer, data map[string]interface{}) error {
+	bw := bufio.NewWriter(w)
+	e := &encoder{w: bw}
+	e.table("", data)
+	if e.err != nil {
+		return e.err
+	}
+	return bw.Flush()
+}
+
+type encoder struct {
+	w     *bufio.Writer
+	first bool // 已输出过内容，表头前需要空行
+	err   error
+}
+
+func (e *encoder) printf(format string, args ...interface{}) {
+	if e.err == nil {
+		_, e.err = fmt.Fprintf(e.w, format, args...)
+		e.first = true
+	}
+}
+
+// 输出一个表的内容，path为表的完整键名。
+func (e *encoder) table(path string, m map[string]interface{}) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if isTable(m[k]) || isTableArray(m[k]) {
+			continue
+		}
+		s, err := tomlValue(m[k])
+		if err != nil {
+			e.err = fmt.Errorf("cfg: %s: %v", joinKey(path, k), err)
+			return
+		}
+		e.printf("%s = %s\n", tomlKey(k), s)
+	}
+
+	for _, k := range keys {
+		key := joinKey(path, tomlKey(k))
+		switch v := m[k].(type) {
+		case map[string]interface{}:
+			e.header("[%s]\n", key)
+			e.table(key, v)
+		case []interface{}:
+			if !isTableArray(v) {
+				continue
+			}
+			for _, t := range v {
+				e.header("[[%s]]\n", key)
+				e.table(key, t.(map[string]interface{}))
+			}
+		}
+	}
+}
+
+func (e *encoder) header(format, key string) {
+	if e.first {
+		e.printf("\n")
+	}
+	e.printf(format, key)
+}
+
+func isTable(v interface{}) bool {
+	_, ok := v.(map[string]interface{})
+	return ok
+}
+
+// 判断是否为非空的表数组。
+func isTableArray(v interface{}) bool {
+	arr, ok := v.([]interface{})
+	if !ok || len(arr) == 0 {
+		return false
+	}
+	for _, e := range arr {
+		if !isTable(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// 返回TOML形式的键名，非裸键加引号。
+func tomlKey(k string) string {
+	if k == "" {
+		return `""`
+	}
+	for _, r := range k {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return tomlString(k)
+		}
+	}
+	return k
+}
+
+// 返回TOML形式的值，表以内联表形式输出。
+func tomlValue(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case string:
+		return tomlString(val), nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	case int64:
+		return strconv.FormatInt(val, 10), nil
+	case float64:
+		switch {
+		case math.IsNaN(val):
+			return "nan", nil
+		case math.IsInf(val, 1):
+			return "inf", nil
+		case math.IsInf(val, -1):
+			return "-inf", nil
+		}
+		s := strconv.FormatFloat(val, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		return s, nil
+	case time.Time:
+		return val.Format(time.RFC3339Nano), nil
+	case []interface{}:
+		parts := make([]string, len(val))
+		for i, e := range val {
+			s, err := tomlValue(e)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return "[" + strings.Join(parts, ", ") + "]", nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			s, err := tomlValue(val[k])
+			if err != nil {
+				return "", err
+			}
+			parts[i] = tomlKey(k) + " = " + s
+		}
+		return "{" + strings.Join(parts, ", ") + "}", nil
+	}
+	return "", fmt.Errorf("unsupported value type %T", v)
+}
+
+// 返回TOML基本字符串形式，转义引号、反斜杠和控制字符。
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
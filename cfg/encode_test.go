@@ -0,0 +1,98 @@
+package cfg
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncodeTOML(t *testing.T) {
+	src := `name = "a \"quoted\"\tvalue"
+ratio = 1.0
+ports = [80, 443]
+
+[mongo]
+servers = "db:27017"
+maxlink = 10
+
+[mongo.auth]
+user = "root"
+
+[[backends]]
+host = "x"
+
+[[backends]]
+host = "y"
+weight = 2
+`
+	dir := tempDir(t)
+	c, err := Load(writeFile(t, dir, "a.toml", src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := c.GetStringMapE("")
+
+	var buf bytes.Buffer
+	if err := EncodeTOML(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "name = ") {
+		t.Errorf("scalars should come first:\n%s", buf.String())
+	}
+
+	c2, err := Load(writeFile(t, dir, "b.toml", buf.String()))
+	if err != nil {
+		t.Fatalf("%v:\n%s", err, buf.String())
+	}
+	data2, _ := c2.GetStringMapE("")
+	if !reflect.DeepEqual(data, data2) {
+		t.Errorf("round trip mismatch:\n%#v\n%#v", data, data2)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := map[string]interface{}{
+		"name":  "a",
+		"mongo": map[string]interface{}{"maxlink": int64(10), "password": "p1"},
+		"hosts": []interface{}{"x"},
+	}
+	new := map[string]interface{}{
+		"name":  "a",
+		"mongo": map[string]interface{}{"maxlink": int64(20), "password": "p2"},
+		"log":   map[string]interface{}{"level": "info"},
+	}
+
+	var got []string
+	for _, ch := range New().RedactChanges(Diff(old, new)) {
+		got = append(got, ch.String())
+	}
+	want := []string{
+		`- hosts = ["x"]`,
+		`+ log.level = "info"`,
+		`~ mongo.maxlink = 10 -> 20`,
+		`~ mongo.password = "******" -> "******"`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff = %q, want %q", got, want)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	dir := tempDir(t)
+	c, err := Load(writeFile(t, dir, "a.toml", "[mongo]\nuser = \"root\"\npassword = \"p\"\napi_key = \"k\"\nmaxlink = 10\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := c.Redacted()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"user": "root", "password": Redacted, "api_key": Redacted, "maxlink": int64(10)}
+	if !reflect.DeepEqual(data["mongo"], want) {
+		t.Errorf("Redacted = %v", data["mongo"])
+	}
+	if c.GetString("mongo.password") != "p" {
+		t.Error("Redacted should not modify the config")
+	}
+}
@@ -0,0 +1,173 @@
+package cfg
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type envOverlay struct {
+	prefix string
+	sep    string
+}
+
+// 启用环境变量覆盖，所有读取接口优先使用环境变量中的值。
+// 配置键对应的环境变量名为prefix加下划线，再加上转为大写、"."替换为sep后的键名，
+// 如prefix为"APP"、sep为"__"时，APP_MONGO__SERVERS覆盖mongo.servers。
+func (c *Config) EnableEnv(prefix, sep string) {
+	c.env.Store(&envOverlay{prefix: prefix, sep: sep})
+	atomic.AddUint64(&c.version, 1)
+}
+
+// 关闭环境变量覆盖。
+func (c *Config) DisableEnv() {
+	c.env.Store((*envOverlay)(nil))
+	atomic.AddUint64(&c.version, 1)
+}
+
+func (c *Config) getEnvOverlay() *envOverlay {
+	e, _ := c.env.Load().(*envOverlay)
+	return e
+}
+
+// 返回配置键对应的环境变量名，未启用环境变量覆盖时返回空串。
+func (c *Config) EnvName(key string) string {
+	e := c.getEnvOverlay()
+	if e == nil {
+		return ""
+	}
+	return e.name(key)
+}
+
+func (e *envOverlay) name(key string) string {
+	name := strings.ToUpper(strings.Replace(key, ".", e.sep, -1))
+	name = strings.Replace(name, "-", "_", -1)
+	if e.prefix == "" {
+		return name
+	}
+	return e.prefix + "_" + name
+}
+
+// 查找配置键对应的环境变量。
+func (c *Config) lookupEnv(key string) (string, bool) {
+	e := c.getEnvOverlay()
+	if e == nil {
+		return "", false
+	}
+	return os.LookupEnv(e.name(key))
+}
+
+// 返回取值来自环境变量的配置键。
+// 配置文件中不存在的键，由环境变量名反推，键名为小写。
+func (c *Config) EnvKeys() []string {
+	e := c.getEnvOverlay()
+	if e == nil {
+		return nil
+	}
+
+	found := make(map[string]bool)
+	names := make(map[string]bool)
+	var walk func(path string, v interface{})
+	walk = func(path string, v interface{}) {
+		if m, ok := v.(map[string]interface{}); ok {
+			for k, sub := range m {
+				walk(joinKey(path, k), sub)
+			}
+			return
+		}
+		name := e.name(path)
+		names[name] = true
+		if _, ok := os.LookupEnv(name); ok {
+			found[path] = true
+		}
+	}
+	if v, ok := c.getValue(""); ok {
+		walk("", v)
+	}
+
+	head := ""
+	if e.prefix != "" {
+		head = e.prefix + "_"
+	}
+	for _, kv := range os.Environ() {
+		name := kv[:strings.Index(kv, "=")]
+		if names[name] || !strings.HasPrefix(name, head) || len(name) == len(head) {
+			continue
+		}
+		key := strings.ToLower(name[len(head):])
+		if e.sep != "" {
+			key = strings.Replace(key, strings.ToLower(e.sep), ".", -1)
+		}
+		found[key] = true
+	}
+
+	keys := make([]string, 0, len(found))
+	for k := range found {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// 返回覆盖了命令行参数和环境变量的配置数据副本，path为v对应的配置键。
+func (c *Config) overlay(path string, v interface{}) interface{} {
+	if c.getEnvOverlay() == nil && c.getFlags() == nil {
+		return copyValue(v)
+	}
+
+	if m, ok := v.(map[string]interface{}); ok {
+		cm := make(map[string]interface{}, len(m))
+		for k, sub := range m {
+			cm[k] = c.overlay(joinKey(path, k), sub)
+		}
+		return cm
+	}
+	if s, ok := c.lookupOverride(path); ok {
+		if cv, ok := coerce(s, v); ok {
+			return cv
+		}
+	}
+	return copyValue(v)
+}
+
+// 将环境变量或命令行参数字符串转换为与like相同的类型，数组以逗号分隔。
+func coerce(s string, like interface{}) (interface{}, bool) {
+	switch l := like.(type) {
+	case nil, string:
+		return s, true
+	case int64:
+		i, err := strconv.ParseInt(s, 0, 64)
+		return i, err == nil
+	case float64:
+		f, err := strconv.ParseFloat(s, 64)
+		return f, err == nil
+	case bool:
+		b, err := strconv.ParseBool(s)
+		return b, err == nil
+	case time.Time:
+		t, err := time.Parse(time.RFC3339Nano, s)
+		return t, err == nil
+	case []interface{}:
+		var elem interface{}
+		if len(l) > 0 {
+			elem = l[0]
+		}
+		if _, ok := elem.(map[string]interface{}); ok {
+			return nil, false
+		}
+		parts := strings.Split(s, ",")
+		arr := make([]interface{}, len(parts))
+		for i, p := range parts {
+			c, ok := coerce(strings.TrimSpace(p), elem)
+			if !ok {
+				return nil, false
+			}
+			arr[i] = c
+		}
+		return arr, true
+	}
+	return nil, false
+}
@@ -0,0 +1,69 @@
+package cfg
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestEnvOverlay(t *testing.T) {
+	dir := tempDir(t)
+	file := writeFile(t, dir, "a.toml", "[mongo]\nservers = \"a:1\"\nmaxlink = 10\nratio = 0.5\nhosts = [\"x\"]\n")
+	if err := LoadConfig(file); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("APP_MONGO__SERVERS", "b:2")
+	os.Setenv("APP_MONGO__MAXLINK", "30")
+	os.Setenv("APP_MONGO__RATIO", "bad")
+	os.Setenv("APP_MONGO__HOSTS", "y, z")
+	os.Setenv("APP_EXTRA__NAME", "e")
+	defer func() {
+		for _, k := range []string{"APP_MONGO__SERVERS", "APP_MONGO__MAXLINK", "APP_MONGO__RATIO", "APP_MONGO__HOSTS", "APP_EXTRA__NAME"} {
+			os.Unsetenv(k)
+		}
+	}()
+
+	if GetString("mongo.servers") != "a:1" {
+		t.Error("env overlay should be opt-in")
+	}
+
+	EnableEnv("APP", "__")
+	defer DisableEnv()
+
+	if v := GetString("mongo.servers"); v != "b:2" {
+		t.Errorf("mongo.servers = %q", v)
+	}
+	if v := GetInt("mongo.maxlink"); v != 30 {
+		t.Errorf("mongo.maxlink = %d", v)
+	}
+	if v := GetFloat64("mongo.ratio", 0.1); v != 0.1 {
+		t.Errorf("unparsable env value should fall back to default, got %v", v)
+	}
+	if _, err := GetFloat64E("mongo.ratio"); err == nil {
+		t.Error("unparsable env value should be reported")
+	}
+	if v := Get("mongo.maxlink"); v != int64(30) {
+		t.Errorf("Get should coerce to file type, got %#v", v)
+	}
+	if v := GetString("extra.name"); v != "e" {
+		t.Errorf("extra.name = %q", v)
+	}
+
+	var m struct {
+		Servers string   `toml:"servers"`
+		MaxLink int      `toml:"maxlink"`
+		Hosts   []string `toml:"hosts"`
+	}
+	if err := Unmarshal("mongo", &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Servers != "b:2" || m.MaxLink != 30 || !reflect.DeepEqual(m.Hosts, []string{"y", "z"}) {
+		t.Errorf("unexpected %+v", m)
+	}
+
+	want := []string{"extra.name", "mongo.hosts", "mongo.maxlink", "mongo.ratio", "mongo.servers"}
+	if keys := EnvKeys(); !reflect.DeepEqual(keys, want) {
+		t.Errorf("EnvKeys = %v", keys)
+	}
+}
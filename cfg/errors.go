@@ -0,0 +1,124 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 配置尚未载入。
+var ErrNotLoaded = errors.New("cfg: config not loaded")
+
+// 配置项不存在。
+type ErrKeyNotFound struct {
+	Key string // 配置键路径
+}
+
+func (e *ErrKeyNotFound) Error() string {
+	return fmt.Sprintf("cfg: key %q not found", e.Key)
+}
+
+// 配置项类型与期望的类型不符。
+type ErrTypeMismatch struct {
+	Key      string // 配置键路径
+	Expected string // 期望的类型
+	Actual   string // 实际的类型
+}
+
+func (e *ErrTypeMismatch) Error() string {
+	return fmt.Sprintf("cfg: %s: expected %s, got %s", e.Key, e.Expected, e.Actual)
+}
+
+// 整数配置项超出目标类型的范围。
+type ErrOverflow struct {
+	Key   string // 配置键路径
+	Value int64  // 配置值
+	Type  string // 目标类型
+}
+
+func (e *ErrOverflow) Error() string {
+	return fmt.Sprintf("cfg: %s: value %d overflows %s", e.Key, e.Value, e.Type)
+}
+
+// 配置值中的引用无法解析。
+type ErrUnresolved struct {
+	Key string // 包含引用的配置键路径
+	Ref string // 引用内容
+}
+
+func (e *ErrUnresolved) Error() string {
+	return fmt.Sprintf("cfg: %s: unresolved reference ${%s}", e.Key, e.Ref)
+}
+
+// 配置值之间存在循环引用。
+type ErrCycle struct {
+	Keys []string // 引用链，首尾相同
+}
+
+func (e *ErrCycle) Error() string {
+	return "cfg: reference cycle: " + strings.Join(e.Keys, " -> ")
+}
+
+// 配置文件之间存在循环包含。
+type ErrIncludeCycle struct {
+	Files []string // 包含链，首尾相同
+}
+
+func (e *ErrIncludeCycle) Error() string {
+	return "cfg: include cycle: " + strings.Join(e.Files, " -> ")
+}
+
+// 严格模式下缺少未提供默认值的配置项。
+type ErrMissingKeys struct {
+	Keys []string // 缺少的配置键路径
+}
+
+func (e *ErrMissingKeys) Error() string {
+	return "cfg: missing required keys: " + strings.Join(e.Keys, ", ")
+}
+
+// 加密的配置值无法解密。
+type ErrDecrypt struct {
+	Key string // 配置键路径
+	Err error  // 原因
+}
+
+func (e *ErrDecrypt) Error() string {
+	return fmt.Sprintf("cfg: %s: decrypt: %v", e.Key, e.Err)
+}
+
+func (e *ErrDecrypt) Unwrap() error {
+	return e.Err
+}
+
+// 模板文件与配置结构生成的模板不一致。
+type ErrStaleTemplate struct {
+	File string // 模板文件路径
+}
+
+func (e *ErrStaleTemplate) Error() string {
+	return "cfg: " + e.File + " is out of date"
+}
+
+// 判断错误是否为配置项不存在。
+func IsNotFound(err error) bool {
+	var e *ErrKeyNotFound
+	return errors.As(err, &e)
+}
+
+func typeMismatch(key, expected string, v interface{}) error {
+	return &ErrTypeMismatch{Key: key, Expected: expected, Actual: typeName(v)}
+}
+
+// 返回配置值的类型名，表和数组使用TOML中的叫法。
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "table"
+	case []interface{}:
+		return "array"
+	case nil:
+		return "nil"
+	}
+	return fmt.Sprintf("%T", v)
+}
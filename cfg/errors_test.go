@@ -0,0 +1,83 @@
+package cfg
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTypedErrors(t *testing.T) {
+	c := New()
+	if _, err := c.GetStringE("a"); err != ErrNotLoaded {
+		t.Errorf("expected ErrNotLoaded, got %v", err)
+	}
+	if v := c.GetString("a"); v != "" {
+		t.Errorf("unloaded getter should return zero value, got %q", v)
+	}
+
+	dir := tempDir(t)
+	if err := c.LoadConfig(writeFile(t, dir, "a.toml", "name = \"svc\"\n[mongo]\nmaxlink = 10\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := c.GetIntE("mongo.missing")
+	var nf *ErrKeyNotFound
+	if !errors.As(err, &nf) || nf.Key != "mongo.missing" || !IsNotFound(err) {
+		t.Errorf("expected ErrKeyNotFound, got %v", err)
+	}
+	if v, err := c.GetIntE("mongo.missing", 5); err != nil || v != 5 {
+		t.Errorf("default should be used, got %v %v", v, err)
+	}
+
+	_, err = c.GetIntE("name")
+	var tm *ErrTypeMismatch
+	if !errors.As(err, &tm) || tm.Key != "name" || tm.Expected != "int64" || tm.Actual != "string" {
+		t.Errorf("expected ErrTypeMismatch, got %v", err)
+	}
+	if v, err := c.GetFloat64E("mongo.maxlink"); err != nil || v != 10 {
+		t.Errorf("int should convert to float, got %v %v", v, err)
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("MustGetString should panic")
+			}
+		}()
+		c.MustGetString("missing")
+	}()
+	if c.MustGetInt("mongo.maxlink") != 10 {
+		t.Error("MustGetInt failed")
+	}
+}
+
+func TestStrict(t *testing.T) {
+	dir := tempDir(t)
+	file := writeFile(t, dir, "a.toml", "[mongo]\nmaxlink = 10\n")
+
+	c := New()
+	c.Declare("mongo.maxlink")
+	c.Declare("mongo.servers")
+	c.Declare("mongo.timeout", 5)
+	c.Declare("log.level")
+	c.SetStrict(true)
+
+	err := c.LoadConfig(file)
+	var mk *ErrMissingKeys
+	if !errors.As(err, &mk) || len(mk.Keys) != 2 || mk.Keys[0] != "log.level" || mk.Keys[1] != "mongo.servers" {
+		t.Fatalf("expected missing keys, got %v", err)
+	}
+
+	c.SetStrict(false)
+	if err := c.LoadConfig(file); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Check(); err == nil {
+		t.Error("Check should report missing keys")
+	}
+	if v := c.GetInt("mongo.timeout"); v != 5 {
+		t.Errorf("declared default should be used, got %d", v)
+	}
+	if v := c.GetInt("mongo.timeout", 8); v != 8 {
+		t.Errorf("getter default should win, got %d", v)
+	}
+}
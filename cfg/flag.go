@@ -0,0 +1,195 @@
+package cfg
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// 将配置项绑定为fs中的命令行参数，参数名与配置键相同，如--mongo.maxlink对应mongo.maxlink。
+// 未指定keys时绑定当前配置中除表和表数组以外的所有配置项。
+// 应在LoadConfig之后、fs.Parse之前调用，参数的默认值即配置文件中的当前值，--help中显示。
+//
+// 配置项的生效值按以下优先级确定：命令行参数、环境变量、配置文件、默认值。
+// 命令行参数中的值按配置文件中原值的类型检查，数组以逗号分隔。
+func (c *Config) BindFlags(fs *flag.FlagSet, keys ...string) error {
+	if len(keys) == 0 {
+		v, ok := c.getValue("")
+		if !ok {
+			return ErrNotLoaded
+		}
+		keys = leafKeys("", v)
+	}
+
+	for _, key := range keys {
+		v, ok := c.getValue(key)
+		if !ok {
+			v, _ = c.declaredDefault(key)
+		}
+		if !bindable(v) {
+			return typeMismatch(key, "scalar or array", v)
+		}
+
+		usage := "config key " + key
+		if loc, ok := c.Source(key); ok {
+			usage += ", default from " + loc.String()
+		}
+		fv := &flagValue{c: c, key: key}
+		if _, ok := v.(bool); ok {
+			fs.Var(&boolFlagValue{fv}, key, usage)
+		} else {
+			fs.Var(fv, key, usage)
+		}
+	}
+	return nil
+}
+
+// 返回命令行参数设置的值，未设置任何参数时为nil。
+func (c *Config) getFlags() map[string]string {
+	m, _ := c.flags.Load().(map[string]string)
+	return m
+}
+
+// 查找配置键对应的命令行参数。
+func (c *Config) lookupFlag(key string) (string, bool) {
+	s, ok := c.getFlags()[key]
+	return s, ok
+}
+
+// 依次按命令行参数、环境变量查找配置键的覆盖值。
+func (c *Config) lookupOverride(key string) (string, bool) {
+	if s, ok := c.lookupFlag(key); ok {
+		return s, true
+	}
+	return c.lookupEnv(key)
+}
+
+// 记录命令行参数设置的值，写时复制，读取无需加锁。
+func (c *Config) setFlag(key, s string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old := c.getFlags()
+	m := make(map[string]string, len(old)+1)
+	for k, v := range old {
+		m[k] = v
+	}
+	m[key] = s
+	c.flags.Store(m)
+	atomic.AddUint64(&c.version, 1)
+}
+
+// 清除命令行参数设置的值，配置项恢复为环境变量或配置文件中的值。
+// 已绑定的参数依然有效，再次解析时重新设置。
+func (c *Config) ClearFlags() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flags.Store(map[string]string(nil))
+	atomic.AddUint64(&c.version, 1)
+}
+
+// 绑定到配置项的命令行参数，实现flag.Value。
+type flagValue struct {
+	c   *Config
+	key string
+}
+
+func (f *flagValue) String() string {
+	if f.c == nil {
+		return ""
+	}
+	if s, ok := f.c.lookupOverride(f.key); ok {
+		return s
+	}
+	v, ok := f.c.getValue(f.key)
+	if !ok {
+		v, _ = f.c.declaredDefault(f.key)
+	}
+	return formatFlag(v)
+}
+
+func (f *flagValue) Set(s string) error {
+	like, ok := f.c.getValue(f.key)
+	if !ok {
+		like, _ = f.c.declaredDefault(f.key)
+	}
+	if _, ok := coerce(s, like); !ok {
+		return fmt.Errorf("expected %s", typeName(like))
+	}
+	f.c.setFlag(f.key, s)
+	return nil
+}
+
+// 布尔配置项对应的命令行参数，可以省略值，如--debug。
+type boolFlagValue struct {
+	*flagValue
+}
+
+// flag包以零值调用String判断是否为默认值，此时flagValue为nil。
+func (f *boolFlagValue) String() string {
+	if f.flagValue == nil {
+		return "false"
+	}
+	return f.flagValue.String()
+}
+
+func (f *boolFlagValue) IsBoolFlag() bool {
+	return true
+}
+
+// 能否绑定为命令行参数，表和表数组不能绑定。
+func bindable(v interface{}) bool {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return false
+	case []interface{}:
+		for _, e := range val {
+			if !bindable(e) {
+				return false
+			}
+			if _, ok := e.([]interface{}); ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// 返回可以绑定为命令行参数的配置键，按字典序排列。
+func leafKeys(path string, v interface{}) []string {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		if bindable(v) {
+			return []string{path}
+		}
+		return nil
+	}
+
+	var keys []string
+	for k, sub := range m {
+		keys = append(keys, leafKeys(joinKey(path, k), sub)...)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// 将配置值格式化为命令行参数形式，与coerce互逆。
+func formatFlag(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	case []interface{}:
+		parts := make([]string, len(val))
+		for i, e := range val {
+			parts[i] = formatFlag(e)
+		}
+		return strings.Join(parts, ",")
+	}
+	return fmt.Sprint(v)
+}
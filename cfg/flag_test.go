@@ -0,0 +1,100 @@
+package cfg
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBindFlags(t *testing.T) {
+	dir := tempDir(t)
+	file := writeFile(t, dir, "a.toml", "[mongo]\nservers = \"a:1\"\nmaxlink = 10\ndebug = false\nhosts = [\"x\", \"y\"]\ntrace = true\n")
+	c, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Declare("log.level", "info")
+
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	if err := c.BindFlags(fs); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.BindFlags(fs, "log.level"); err != nil {
+		t.Fatal(err)
+	}
+
+	var help bytes.Buffer
+	fs.SetOutput(&help)
+	fs.PrintDefaults()
+	for _, s := range []string{"-mongo.maxlink", "(default 10)", "a.toml:3", "(default x,y)", "(default info)", "(default true)"} {
+		if !strings.Contains(help.String(), s) {
+			t.Errorf("help output missing %q:\n%s", s, help.String())
+		}
+	}
+	if strings.Contains(help.String(), "panic") || strings.Contains(help.String(), "(default false)") {
+		t.Errorf("bad help output:\n%s", help.String())
+	}
+
+	os.Setenv("APP_MONGO__MAXLINK", "20")
+	os.Setenv("APP_MONGO__SERVERS", "env:2")
+	defer os.Unsetenv("APP_MONGO__MAXLINK")
+	defer os.Unsetenv("APP_MONGO__SERVERS")
+	c.EnableEnv("APP", "__")
+
+	if err := fs.Parse([]string{"--mongo.maxlink=30", "--mongo.debug", "--mongo.hosts", "p,q", "--log.level", "warn"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := c.GetInt("mongo.maxlink"); v != 30 {
+		t.Errorf("flag should override env, mongo.maxlink = %d", v)
+	}
+	if v := c.GetString("mongo.servers"); v != "env:2" {
+		t.Errorf("env should override file, mongo.servers = %q", v)
+	}
+	if v := c.GetBool("mongo.debug"); !v {
+		t.Error("mongo.debug should be set by bool flag")
+	}
+	if v := c.GetString("log.level"); v != "warn" {
+		t.Errorf("flag should override declared default, log.level = %q", v)
+	}
+
+	var m struct {
+		MaxLink int      `toml:"maxlink"`
+		Hosts   []string `toml:"hosts"`
+	}
+	if err := c.Unmarshal("mongo", &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.MaxLink != 30 || strings.Join(m.Hosts, ",") != "p,q" {
+		t.Errorf("Unmarshal = %+v", m)
+	}
+
+	if loc, _ := c.Source("mongo.maxlink"); loc.File != "flag:--mongo.maxlink" {
+		t.Errorf("Source = %v", loc)
+	}
+}
+
+func TestBindFlagsInvalid(t *testing.T) {
+	dir := tempDir(t)
+	c, err := Load(writeFile(t, dir, "a.toml", "[mongo]\nmaxlink = 10\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	fs.SetOutput(&bytes.Buffer{})
+	if err := c.BindFlags(fs, "mongo"); err == nil {
+		t.Error("binding a table should fail")
+	}
+	if err := c.BindFlags(fs, "mongo.maxlink"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse([]string{"--mongo.maxlink=abc"}); err == nil {
+		t.Error("expected error for non-integer flag value")
+	}
+	if v := c.GetInt("mongo.maxlink"); v != 10 {
+		t.Errorf("mongo.maxlink = %d", v)
+	}
+}
@@ -0,0 +1,302 @@
+/*
+包flags，基于配置的功能开关，读取配置中的[flags]表，配置重新载入后自动生效。
+
+每个开关可以是布尔值，也可以是表：
+
+	[flags]
+	new_ui = true
+
+	[flags.checkout_v2]
+	enabled = true                  # 总开关，默认为true
+	percent = 25                    # 按用户灰度的百分比，0-100，默认为100
+	allow = ["u1", "u2"]            # 总是开启的用户
+	deny = ["u3"]                   # 总是关闭的用户
+	start = 2024-01-01T00:00:00Z    # 生效时间，也可以为RFC3339格式的字符串
+	end = 2024-02-01T00:00:00Z      # 失效时间
+
+判断顺序为：enabled为false时关闭；用户在deny中时关闭；在allow中时开启；
+不在start、end时间范围内时关闭；最后按用户ID的稳定哈希值落在percent范围内时开启。
+未配置或配置有误的开关总是关闭。
+*/
+package flags
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/betterjun/pkg/cfg"
+)
+
+// 功能开关的默认配置键。
+const Section = "flags"
+
+// 一个功能开关。
+type Flag struct {
+	Name    string
+	Enabled bool            // 总开关
+	Percent float64         // 灰度百分比，0-100
+	Allow   map[string]bool // 总是开启的用户
+	Deny    map[string]bool // 总是关闭的用户
+	Start   time.Time       // 生效时间，零值表示不限
+	End     time.Time       // 失效时间，零值表示不限
+}
+
+// 判断开关在now时刻对subject是否开启。
+func (f *Flag) On(subject string, now time.Time) bool {
+	if f == nil || !f.Enabled {
+		return false
+	}
+	if f.Deny[subject] {
+		return false
+	}
+	if f.Allow[subject] {
+		return true
+	}
+	if !f.Start.IsZero() && now.Before(f.Start) {
+		return false
+	}
+	if !f.End.IsZero() && !now.Before(f.End) {
+		return false
+	}
+	if f.Percent >= 100 {
+		return true
+	}
+	if f.Percent <= 0 || subject == "" {
+		return false
+	}
+	return float64(Bucket(f.Name, subject)) < f.Percent*100
+}
+
+// 返回subject在开关name下的灰度分桶，取值0-9999，同一用户在同一开关下总是相同。
+func Bucket(name, subject string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(subject))
+	return h.Sum32() % 10000
+}
+
+// 解析结果。
+type state struct {
+	flags map[string]*Flag
+	err   error
+}
+
+// 一组功能开关，从配置对象的某个表读取，配置变化时自动更新，可以并发访问。
+type Set struct {
+	cancel func()
+
+	mu    sync.Mutex   // 串行化更新
+	state atomic.Value // *state
+}
+
+// 创建功能开关集合，读取c中section对应的表，如Section。
+func New(c *cfg.Config, section string) *Set {
+	s := &Set{}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancel = c.OnChange(section, func(old, new interface{}) {
+		s.mu.Lock()
+		s.update(section, new)
+		s.mu.Unlock()
+	})
+	v, _ := c.GetE(section)
+	s.update(section, v)
+	return s
+}
+
+// 停止跟随配置更新。
+func (s *Set) Close() {
+	s.cancel()
+}
+
+// 判断功能开关name对subject（通常为用户ID）是否开启，subject可以为空串。
+// 判断时刻默认为当前时间，可以用WithTime指定。
+func (s *Set) Enabled(ctx context.Context, name, subject string) bool {
+	return s.Get(name).On(subject, now(ctx))
+}
+
+// 返回功能开关，不存在或配置有误时返回nil。
+func (s *Set) Get(name string) *Flag {
+	return s.state.Load().(*state).flags[name]
+}
+
+// 返回所有有效的功能开关名，按字典序排列。
+func (s *Set) Names() []string {
+	m := s.state.Load().(*state).flags
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 返回最近一次解析配置时的错误。
+func (s *Set) Err() error {
+	return s.state.Load().(*state).err
+}
+
+func (s *Set) update(section string, v interface{}) {
+	st := &state{flags: make(map[string]*Flag)}
+	m, ok := v.(map[string]interface{})
+	if !ok && v != nil {
+		st.err = fmt.Errorf("flags: %s: expected table, got %T", section, v)
+	}
+
+	var errs []string
+	for name, def := range m {
+		f, err := parse(name, def)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s.%s: %v", section, name, err))
+			continue
+		}
+		st.flags[name] = f
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		st.err = fmt.Errorf("flags: %s", strings.Join(errs, "; "))
+	}
+	s.state.Store(st)
+}
+
+// 解析一个功能开关的配置。
+func parse(name string, v interface{}) (*Flag, error) {
+	f := &Flag{Name: name, Enabled: true, Percent: 100}
+	switch val := v.(type) {
+	case bool:
+		f.Enabled = val
+		return f, nil
+	case map[string]interface{}:
+		for k, e := range val {
+			var err error
+			switch k {
+			case "enabled":
+				b, ok := e.(bool)
+				if !ok {
+					err = fmt.Errorf("expected bool, got %T", e)
+				}
+				f.Enabled = b
+			case "percent":
+				f.Percent, err = toPercent(e)
+			case "allow":
+				f.Allow, err = toSet(e)
+			case "deny":
+				f.Deny, err = toSet(e)
+			case "start":
+				f.Start, err = toTime(e)
+			case "end":
+				f.End, err = toTime(e)
+			default:
+				err = fmt.Errorf("unknown field")
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", k, err)
+			}
+		}
+		return f, nil
+	}
+	return nil, fmt.Errorf("expected bool or table, got %T", v)
+}
+
+func toPercent(v interface{}) (float64, error) {
+	var p float64
+	switch n := v.(type) {
+	case int64:
+		p = float64(n)
+	case float64:
+		p = n
+	default:
+		return 0, fmt.Errorf("expected number, got %T", v)
+	}
+	if p < 0 || p > 100 {
+		return 0, fmt.Errorf("%v out of range 0-100", p)
+	}
+	return p, nil
+}
+
+func toSet(v interface{}) (map[string]bool, error) {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected array, got %T", v)
+	}
+	m := make(map[string]bool, len(arr))
+	for _, e := range arr {
+		s, ok := e.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected string, got %T", e)
+		}
+		m[s] = true
+	}
+	return m, nil
+}
+
+func toTime(v interface{}) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case string:
+		return time.Parse(time.RFC3339, t)
+	}
+	return time.Time{}, fmt.Errorf("expected time, got %T", v)
+}
+
+type timeKey struct{}
+
+// 返回指定判断时刻的ctx，用于测试或回放。
+func WithTime(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, timeKey{}, t)
+}
+
+func now(ctx context.Context) time.Time {
+	if ctx != nil {
+		if t, ok := ctx.Value(timeKey{}).(time.Time); ok {
+			return t
+		}
+	}
+	return time.Now()
+}
+
+// 默认功能开关集合及其对应的配置对象。
+type binding struct {
+	c *cfg.Config
+	s *Set
+}
+
+var (
+	stdMu sync.Mutex
+	std   atomic.Value // *binding
+)
+
+// 返回cfg.Default()中Section表对应的功能开关集合，默认配置对象被替换时随之重建。
+func Default() *Set {
+	c := cfg.Default()
+	if b, ok := std.Load().(*binding); ok && b.c == c {
+		return b.s
+	}
+
+	stdMu.Lock()
+	defer stdMu.Unlock()
+	b, _ := std.Load().(*binding)
+	if b != nil && b.c == c {
+		return b.s
+	}
+	if b != nil {
+		b.s.Close()
+	}
+	b = &binding{c: c, s: New(c, Section)}
+	std.Store(b)
+	return b.s
+}
+
+// 判断默认配置中的功能开关name对subject是否开启。
+func Enabled(ctx context.Context, name, subject string) bool {
+	return Default().Enabled(ctx, name, subject)
+}
@@ -0,0 +1,140 @@
+package flags
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/betterjun/pkg/cfg"
+)
+
+const flagsToml = `[flags]
+new_ui = true
+old_ui = false
+
+[flags.checkout]
+percent = 30
+allow = ["vip"]
+deny = ["banned"]
+
+[flags.sale]
+start = 2024-01-01T00:00:00Z
+end = "2024-02-01T00:00:00Z"
+
+[flags.killed]
+enabled = false
+allow = ["vip"]
+
+[flags.broken]
+percent = 200
+`
+
+func load(t *testing.T, content string) (*cfg.Config, string) {
+	dir, err := ioutil.TempDir("", "flags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	file := filepath.Join(dir, "app.toml")
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := cfg.Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, file
+}
+
+func TestEnabled(t *testing.T) {
+	c, _ := load(t, flagsToml)
+	s := New(c, Section)
+	defer s.Close()
+	ctx := context.Background()
+
+	cases := []struct {
+		name, subject string
+		want          bool
+	}{
+		{"new_ui", "", true},
+		{"old_ui", "u1", false},
+		{"missing", "u1", false},
+		{"checkout", "vip", true},
+		{"checkout", "banned", false},
+		{"checkout", "", false},
+		{"killed", "vip", false},
+		{"broken", "u1", false},
+	}
+	for _, tc := range cases {
+		if got := s.Enabled(ctx, tc.name, tc.subject); got != tc.want {
+			t.Errorf("Enabled(%q, %q) = %v", tc.name, tc.subject, got)
+		}
+	}
+	if s.Err() == nil {
+		t.Error("invalid flag should be reported")
+	}
+
+	before := WithTime(ctx, time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC))
+	during := WithTime(ctx, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC))
+	after := WithTime(ctx, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+	if s.Enabled(before, "sale", "u") || !s.Enabled(during, "sale", "u") || s.Enabled(after, "sale", "u") {
+		t.Error("time window not applied")
+	}
+}
+
+func TestRollout(t *testing.T) {
+	c, _ := load(t, flagsToml)
+	s := New(c, Section)
+	defer s.Close()
+	ctx := context.Background()
+
+	on := 0
+	for i := 0; i < 10000; i++ {
+		id := fmt.Sprintf("user-%d", i)
+		got := s.Enabled(ctx, "checkout", id)
+		if got != s.Enabled(ctx, "checkout", id) {
+			t.Fatalf("rollout for %s is not stable", id)
+		}
+		if got {
+			on++
+		}
+	}
+	if on < 2700 || on > 3300 {
+		t.Errorf("30%% rollout enabled %d of 10000", on)
+	}
+}
+
+func TestReload(t *testing.T) {
+	c, file := load(t, flagsToml)
+	s := New(c, Section)
+	defer s.Close()
+
+	if err := ioutil.WriteFile(file, []byte("[flags]\nnew_ui = false\nadded = true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if s.Enabled(ctx, "new_ui", "") || !s.Enabled(ctx, "added", "") {
+		t.Error("flags not updated on reload")
+	}
+	if s.Err() != nil {
+		t.Errorf("Err = %v", s.Err())
+	}
+}
+
+func TestDefault(t *testing.T) {
+	c, _ := load(t, "[flags]\non = true\n")
+	old := cfg.Default()
+	cfg.SetDefault(c)
+	defer cfg.SetDefault(old)
+
+	if !Enabled(context.Background(), "on", "") {
+		t.Error("default set should follow cfg.Default")
+	}
+}
@@ -0,0 +1,384 @@
+package cfg
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 获取布尔配置数据。
+func (c *Config) GetBool(key string, def ...bool) (ret bool) {
+	ret, err := c.GetBoolE(key, def...)
+	if err != nil && len(def) > 0 {
+		return def[0]
+	}
+	return ret
+}
+
+// 获取布尔配置数据，配置项不存在且未指定默认值，或类型不符时返回错误。
+func (c *Config) GetBoolE(key string, def ...bool) (ret bool, err error) {
+	v, env, err := c.find(key, len(def) > 0)
+	if err == errUseDef {
+		return def[0], nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if env {
+		b, err := strconv.ParseBool(v.(string))
+		if err != nil {
+			return false, typeMismatch(key, "bool", v)
+		}
+		return b, nil
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, typeMismatch(key, "bool", v)
+	}
+	return b, nil
+}
+
+// 获取布尔配置数据，出错时panic。
+func (c *Config) MustGetBool(key string) bool {
+	ret, err := c.GetBoolE(key)
+	if err != nil {
+		panic(err)
+	}
+	return ret
+}
+
+// 获取时长配置数据。字符串按time.ParseDuration解析，如"1m30s"；整数及整数字符串表示秒数。
+func (c *Config) GetDuration(key string, def ...time.Duration) (ret time.Duration) {
+	ret, err := c.GetDurationE(key, def...)
+	if err != nil && len(def) > 0 {
+		return def[0]
+	}
+	return ret
+}
+
+// 获取时长配置数据，配置项不存在且未指定默认值，或格式有误时返回错误。
+func (c *Config) GetDurationE(key string, def ...time.Duration) (ret time.Duration, err error) {
+	v, _, err := c.find(key, len(def) > 0)
+	if err == errUseDef {
+		return def[0], nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	d, ok := toDuration(v)
+	if !ok {
+		return 0, typeMismatch(key, "duration", v)
+	}
+	return d, nil
+}
+
+// 获取时长配置数据，出错时panic。
+func (c *Config) MustGetDuration(key string) time.Duration {
+	ret, err := c.GetDurationE(key)
+	if err != nil {
+		panic(err)
+	}
+	return ret
+}
+
+// 获取时间配置数据。支持TOML日期时间，以及RFC3339格式的字符串。
+func (c *Config) GetTime(key string, def ...time.Time) (ret time.Time) {
+	ret, err := c.GetTimeE(key, def...)
+	if err != nil && len(def) > 0 {
+		return def[0]
+	}
+	return ret
+}
+
+// 获取时间配置数据，配置项不存在且未指定默认值，或格式有误时返回错误。
+func (c *Config) GetTimeE(key string, def ...time.Time) (ret time.Time, err error) {
+	v, _, err := c.find(key, len(def) > 0)
+	if err == errUseDef {
+		return def[0], nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case string:
+		if ret, err := time.Parse(time.RFC3339Nano, t); err == nil {
+			return ret, nil
+		}
+	}
+	return time.Time{}, typeMismatch(key, "time", v)
+}
+
+// 获取时间配置数据，出错时panic。
+func (c *Config) MustGetTime(key string) time.Time {
+	ret, err := c.GetTimeE(key)
+	if err != nil {
+		panic(err)
+	}
+	return ret
+}
+
+// 获取字符串数组配置数据，def为默认的数组。环境变量中的值以逗号分隔。
+func (c *Config) GetStringSlice(key string, def ...string) (ret []string) {
+	ret, err := c.GetStringSliceE(key, def...)
+	if err != nil && len(def) > 0 {
+		return def
+	}
+	return ret
+}
+
+// 获取字符串数组配置数据，配置项不存在且未指定默认值，或类型不符时返回错误。
+func (c *Config) GetStringSliceE(key string, def ...string) (ret []string, err error) {
+	v, env, err := c.find(key, len(def) > 0)
+	if err == errUseDef {
+		return def, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if env {
+		return splitEnv(v.(string)), nil
+	}
+	arr, ok := v.([]interface{})
+	if !ok {
+		return nil, typeMismatch(key, "[]string", v)
+	}
+	ret = make([]string, len(arr))
+	for i, e := range arr {
+		s, ok := e.(string)
+		if !ok {
+			return nil, typeMismatch(key+"["+strconv.Itoa(i)+"]", "string", e)
+		}
+		ret[i] = s
+	}
+	return ret, nil
+}
+
+// 获取字符串数组配置数据，出错时panic。
+func (c *Config) MustGetStringSlice(key string) []string {
+	ret, err := c.GetStringSliceE(key)
+	if err != nil {
+		panic(err)
+	}
+	return ret
+}
+
+// 获取整数数组配置数据，def为默认的数组。环境变量中的值以逗号分隔。
+func (c *Config) GetIntSlice(key string, def ...int) (ret []int) {
+	ret, err := c.GetIntSliceE(key, def...)
+	if err != nil && len(def) > 0 {
+		return def
+	}
+	return ret
+}
+
+// 获取整数数组配置数据，配置项不存在且未指定默认值，类型不符或超出范围时返回错误。
+func (c *Config) GetIntSliceE(key string, def ...int) (ret []int, err error) {
+	v, env, err := c.find(key, len(def) > 0)
+	if err == errUseDef {
+		return def, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var arr []interface{}
+	if env {
+		for _, s := range splitEnv(v.(string)) {
+			i, err := strconv.ParseInt(s, 0, 64)
+			if err != nil {
+				return nil, typeMismatch(key, "[]int", v)
+			}
+			arr = append(arr, i)
+		}
+	} else {
+		a, ok := v.([]interface{})
+		if !ok {
+			return nil, typeMismatch(key, "[]int", v)
+		}
+		arr = a
+	}
+
+	ret = make([]int, len(arr))
+	for i, e := range arr {
+		k := key + "[" + strconv.Itoa(i) + "]"
+		n, ok := e.(int64)
+		if !ok {
+			return nil, typeMismatch(k, "int64", e)
+		}
+		if int64(int(n)) != n {
+			return nil, &ErrOverflow{Key: k, Value: n, Type: "int"}
+		}
+		ret[i] = int(n)
+	}
+	return ret, nil
+}
+
+// 获取整数数组配置数据，出错时panic。
+func (c *Config) MustGetIntSlice(key string) []int {
+	ret, err := c.GetIntSliceE(key)
+	if err != nil {
+		panic(err)
+	}
+	return ret
+}
+
+// 获取表配置数据，返回的map可以修改，不影响配置本身。
+func (c *Config) GetStringMap(key string, def ...map[string]interface{}) (ret map[string]interface{}) {
+	ret, err := c.GetStringMapE(key, def...)
+	if err != nil && len(def) > 0 {
+		return def[0]
+	}
+	return ret
+}
+
+// 获取表配置数据，配置项不存在且未指定默认值，或类型不符时返回错误。
+func (c *Config) GetStringMapE(key string, def ...map[string]interface{}) (ret map[string]interface{}, err error) {
+	v, _, err := c.find(key, len(def) > 0)
+	if err == errUseDef {
+		return def[0], nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, typeMismatch(key, "table", v)
+	}
+	return c.overlay(key, m).(map[string]interface{}), nil
+}
+
+// 获取表配置数据，出错时panic。
+func (c *Config) MustGetStringMap(key string) map[string]interface{} {
+	ret, err := c.GetStringMapE(key)
+	if err != nil {
+		panic(err)
+	}
+	return ret
+}
+
+// 获取字节数配置数据。字符串支持B、K、M、G、T单位（不区分大小写，可带B或iB后缀），
+// 按1024进制换算，如"64MB"为67108864；整数直接表示字节数。
+func (c *Config) GetSize(key string, def ...int64) (ret int64) {
+	ret, err := c.GetSizeE(key, def...)
+	if err != nil && len(def) > 0 {
+		return def[0]
+	}
+	return ret
+}
+
+// 获取字节数配置数据，配置项不存在且未指定默认值，或格式有误时返回错误。
+func (c *Config) GetSizeE(key string, def ...int64) (ret int64, err error) {
+	v, _, err := c.find(key, len(def) > 0)
+	if err == errUseDef {
+		return def[0], nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	switch n := v.(type) {
+	case int64:
+		if n >= 0 {
+			return n, nil
+		}
+	case string:
+		size, err := ParseSize(n)
+		if err == errSizeOverflow {
+			return 0, &ErrOverflow{Key: key, Value: math.MaxInt64, Type: "int64"}
+		}
+		if err == nil {
+			return size, nil
+		}
+	}
+	return 0, typeMismatch(key, "size", v)
+}
+
+// 获取字节数配置数据，出错时panic。
+func (c *Config) MustGetSize(key string) int64 {
+	ret, err := c.GetSizeE(key)
+	if err != nil {
+		panic(err)
+	}
+	return ret
+}
+
+var errSizeOverflow = errors.New("cfg: size overflows int64")
+
+// 字节数单位。
+var sizeUnits = map[string]int64{
+	"":  1,
+	"k": 1 << 10,
+	"m": 1 << 20,
+	"g": 1 << 30,
+	"t": 1 << 40,
+	"p": 1 << 50,
+}
+
+// 解析字节数字符串，如"512"、"64MB"、"1.5G"、"10KiB"，按1024进制换算。
+func ParseSize(s string) (int64, error) {
+	str := strings.ToLower(strings.TrimSpace(s))
+	str = strings.TrimSuffix(str, "b")
+	str = strings.TrimSuffix(str, "i")
+
+	i := len(str)
+	for i > 0 && (str[i-1] < '0' || str[i-1] > '9') && str[i-1] != '.' {
+		i--
+	}
+	num, unit := strings.TrimSpace(str[:i]), strings.TrimSpace(str[i:])
+	mul, ok := sizeUnits[unit]
+	if !ok || num == "" {
+		return 0, errors.New("cfg: invalid size " + strconv.Quote(s))
+	}
+
+	if n, err := strconv.ParseInt(num, 10, 64); err == nil {
+		if n < 0 {
+			return 0, errors.New("cfg: invalid size " + strconv.Quote(s))
+		}
+		if n > math.MaxInt64/mul {
+			return 0, errSizeOverflow
+		}
+		return n * mul, nil
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || f < 0 {
+		return 0, errors.New("cfg: invalid size " + strconv.Quote(s))
+	}
+	if f*float64(mul) >= math.MaxInt64 {
+		return 0, errSizeOverflow
+	}
+	return int64(f * float64(mul)), nil
+}
+
+// 将配置值转换为时长，字符串按time.ParseDuration解析，整数表示秒数。
+// 来自环境变量和命令行的值为字符串，其中的整数同样表示秒数。
+func toDuration(v interface{}) (time.Duration, bool) {
+	switch d := v.(type) {
+	case string:
+		if n, err := strconv.ParseInt(strings.TrimSpace(d), 10, 64); err == nil {
+			return toDuration(n)
+		}
+		ret, err := time.ParseDuration(d)
+		return ret, err == nil
+	case int64:
+		if d > math.MaxInt64/int64(time.Second) || d < math.MinInt64/int64(time.Second) {
+			return 0, false
+		}
+		return time.Duration(d) * time.Second, true
+	}
+	return 0, false
+}
+
+// 拆分环境变量中逗号分隔的数组。
+func splitEnv(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return []string{}
+	}
+	parts := strings.Split(s, ",")
+	for i, p := range parts {
+		parts[i] = strings.TrimSpace(p)
+	}
+	return parts
+}
@@ -0,0 +1,112 @@
+package cfg
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const gettersToml = `
+debug = true
+timeout = "1m30s"
+retry = 3
+start = 2019-05-27T07:32:00Z
+hosts = ["a", "b"]
+ports = [80, 443]
+big = 3000000000
+cache = "64MB"
+buffer = 4096
+negative = -5
+[labels]
+zone = "cn"
+`
+
+func TestTypedGetters(t *testing.T) {
+	dir := tempDir(t)
+	c, err := Load(writeFile(t, dir, "a.toml", gettersToml))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !c.GetBool("debug") || !c.GetBool("missing", true) {
+		t.Error("GetBool failed")
+	}
+	if d := c.GetDuration("timeout"); d != 90*time.Second {
+		t.Errorf("timeout = %v", d)
+	}
+	if d := c.GetDuration("retry"); d != 3*time.Second {
+		t.Errorf("retry = %v", d)
+	}
+	if tm := c.GetTime("start"); !tm.Equal(time.Date(2019, 5, 27, 7, 32, 0, 0, time.UTC)) {
+		t.Errorf("start = %v", tm)
+	}
+	if s := c.GetStringSlice("hosts"); !reflect.DeepEqual(s, []string{"a", "b"}) {
+		t.Errorf("hosts = %v", s)
+	}
+	if s := c.GetStringSlice("missing", "x"); !reflect.DeepEqual(s, []string{"x"}) {
+		t.Errorf("default slice = %v", s)
+	}
+	if s := c.GetIntSlice("ports"); !reflect.DeepEqual(s, []int{80, 443}) {
+		t.Errorf("ports = %v", s)
+	}
+	if m := c.GetStringMap("labels"); m["zone"] != "cn" {
+		t.Errorf("labels = %v", m)
+	}
+	if n := c.GetSize("cache"); n != 64<<20 {
+		t.Errorf("cache = %d", n)
+	}
+	if n := c.GetSize("buffer"); n != 4096 {
+		t.Errorf("buffer = %d", n)
+	}
+	if _, err := c.GetSizeE("negative"); err == nil {
+		t.Error("negative size should fail")
+	}
+
+	_, err = c.GetInt32E("big")
+	var of *ErrOverflow
+	if !errors.As(err, &of) || of.Key != "big" || of.Type != "int32" {
+		t.Errorf("expected overflow, got %v", err)
+	}
+	if v := c.GetInt32("big", 7); v != 7 {
+		t.Errorf("overflow should fall back to default, got %d", v)
+	}
+	if _, err := c.GetDurationE("hosts"); err == nil {
+		t.Error("array is not a duration")
+	}
+
+	c.Declare("idle", 5*time.Second)
+	if d := c.GetDuration("idle"); d != 5*time.Second {
+		t.Errorf("declared idle = %v", d)
+	}
+
+	t.Setenv("APP_RETRY", "5")
+	c.EnableEnv("APP", "__")
+	if d := c.GetDuration("retry"); d != 5*time.Second {
+		t.Errorf("retry from env = %v", d)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{
+		"512":   512,
+		"10KiB": 10 << 10,
+		"64MB":  64 << 20,
+		"1.5g":  3 << 29,
+		"2 T":   2 << 40,
+		"8b":    8,
+	}
+	for s, want := range cases {
+		if got, err := ParseSize(s); err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v", s, got, err)
+		}
+	}
+	for _, s := range []string{"", "MB", "12XB", "-1K", "99999999999P"} {
+		if _, err := ParseSize(s); err == nil {
+			t.Errorf("ParseSize(%q) should fail", s)
+		}
+	}
+	if _, err := ParseSize("-5MB"); err == nil || err == errSizeOverflow {
+		t.Errorf("ParseSize(-5MB) = %v, want invalid size", err)
+	}
+}
@@ -0,0 +1,114 @@
+package cfg
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInclude(t *testing.T) {
+	dir := tempDir(t)
+	if err := os.Mkdir(filepath.Join(dir, "conf.d"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	main := writeFile(t, dir, "app.toml", "include = [\"conf.d/*.toml\"]\nname = \"app\"\n[mongo]\nmaxlink = 10\nservers = \"a\"\n")
+	writeFile(t, dir, "conf.d/20-mongo.toml", "[mongo]\nmaxlink = 30\n")
+	writeFile(t, dir, "conf.d/10-mongo.toml", "[mongo]\nmaxlink = 20\nservers = \"b\"\n")
+	writeFile(t, dir, "conf.d/30-log.yaml", "log:\n  level: debug\n")
+
+	c, err := Load(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := c.GetInt("mongo.maxlink"); v != 30 {
+		t.Errorf("fragments should apply in sorted order, mongo.maxlink = %d", v)
+	}
+	if v := c.GetString("mongo.servers"); v != "b" {
+		t.Errorf("mongo.servers = %q", v)
+	}
+	if v := c.GetString("log.level"); v != "" {
+		t.Errorf("non-matching fragment should be ignored, log.level = %q", v)
+	}
+	if _, err := c.GetE("include"); !IsNotFound(err) {
+		t.Errorf("include should not be a config key: %v", err)
+	}
+
+	want := Location{File: filepath.Join(dir, "conf.d/10-mongo.toml"), Line: 3}
+	if loc, _ := c.Source("mongo.servers"); loc != want {
+		t.Errorf("Source = %v, want %v", loc, want)
+	}
+}
+
+func TestIncludeNested(t *testing.T) {
+	dir := tempDir(t)
+	if err := os.MkdirAll(filepath.Join(dir, "sub", "deeper"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	main := writeFile(t, dir, "app.toml", "include = \"sub/a.toml\"\nv = 1\n")
+	writeFile(t, dir, "sub/a.toml", "include = [\"deeper/b.toml\"]\nv = 2\n")
+	writeFile(t, dir, "sub/deeper/b.toml", "v = 3\nw = 4\n")
+
+	c, err := Load(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := c.GetInt("v"); v != 3 {
+		t.Errorf("v = %d", v)
+	}
+
+	writeFile(t, dir, "sub/deeper/b.toml", "include = [\"../../app.toml\"]\n")
+	err = c.Reload()
+	var ce *ErrIncludeCycle
+	if !errors.As(err, &ce) || len(ce.Files) != 4 {
+		t.Fatalf("Reload = %v", err)
+	}
+	if ce.Files[0] != ce.Files[3] {
+		t.Errorf("cycle should start and end with the same file: %v", ce.Files)
+	}
+
+	writeFile(t, dir, "sub/a.toml", "include = [\"missing.toml\"]\n")
+	if err := c.Reload(); err == nil {
+		t.Error("including a missing file should fail")
+	}
+}
+
+func TestWatchInclude(t *testing.T) {
+	dir := tempDir(t)
+	if err := os.Mkdir(filepath.Join(dir, "conf.d"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	main := writeFile(t, dir, "app.toml", "include = [\"conf.d/*.toml\"]\nv = 1\n")
+	c, err := Load(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed := make(chan struct{}, 2)
+	defer c.OnChange("v", func(old, new interface{}) { changed <- struct{}{} })()
+	stop := c.Watch(10*time.Millisecond, nil)
+	defer stop()
+
+	writeFile(t, dir, "conf.d/a.toml", "v = 2\n")
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("watch did not pick up new fragment")
+	}
+	if v := c.GetInt("v"); v != 2 {
+		t.Errorf("v = %d", v)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	writeFile(t, dir, "conf.d/a.toml", "v = 33\n")
+	// 写文件时先截断，监视可能先读到空文件，等待最终的值
+	timeout := time.After(2 * time.Second)
+	for c.GetInt("v") != 33 {
+		select {
+		case <-changed:
+		case <-timeout:
+			t.Fatalf("watch did not pick up modified fragment, v = %d", c.GetInt("v"))
+		}
+	}
+}
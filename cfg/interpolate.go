@@ -0,0 +1,197 @@
+package cfg
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// 解析配置中字符串值里的引用，载入时执行：
+//
+//	${other.key}        引用其他配置项，整个字符串仅为一个引用时保留被引用值的类型
+//	${ENV_VAR}          引用环境变量
+//	${name:default}     name依次按配置项、环境变量查找，都不存在时使用default
+//	$${                 转义，表示字面的${
+//
+// 加密的值解密后原样使用，不解析其中的引用。
+// 引用了敏感配置项的配置键记入snap.secrets，同样视为敏感配置项。
+// 存在循环引用时返回*ErrCycle，引用无法解析时返回*ErrUnresolved。
+func (c *Config) interpolate(snap *snapshot) error {
+	r := &resolver{c: c, snap: snap, data: snap.data, done: make(map[string]bool)}
+	_, err := r.node("", snap.data)
+	return err
+}
+
+type resolver struct {
+	c     *Config
+	snap  *snapshot
+	data  map[string]interface{}
+	done  map[string]bool // 已解析的配置键
+	stack []string        // 正在解析的配置键，用于检测循环引用
+}
+
+// 解析path对应的值v，返回解析后的值。
+func (r *resolver) node(path string, v interface{}) (interface{}, error) {
+	if r.done[path] {
+		return v, nil
+	}
+	for i, p := range r.stack {
+		if p == path {
+			cycle := append(append([]string(nil), r.stack[i:]...), path)
+			return nil, &ErrCycle{Keys: cycle}
+		}
+	}
+	r.stack = append(r.stack, path)
+	defer func() { r.stack = r.stack[:len(r.stack)-1] }()
+
+	switch val := v.(type) {
+	case string:
+		// 解密得到的值原样保留，其中的"${"不是引用
+		if r.snap.encrypted[path] {
+			break
+		}
+		s, err := r.str(path, val)
+		if err != nil {
+			return nil, err
+		}
+		v = s
+	case map[string]interface{}:
+		for k, e := range val {
+			ne, err := r.node(joinKey(path, k), e)
+			if err != nil {
+				return nil, err
+			}
+			val[k] = ne
+		}
+	case []interface{}:
+		for i, e := range val {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			ne, err := r.node(p, e)
+			if err != nil {
+				return nil, err
+			}
+			val[i] = ne
+			// 标量数组中有敏感值时，整个数组视为敏感配置项
+			if !isTable(ne) && r.snap.secrets[p] {
+				delete(r.snap.secrets, p)
+				r.snap.secrets[path] = true
+			}
+		}
+	}
+	r.done[path] = true
+	return v, nil
+}
+
+// 解析字符串中的引用。
+func (r *resolver) str(path, s string) (interface{}, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var buf strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			buf.WriteString(s)
+			return buf.String(), nil
+		}
+		if i > 0 && s[i-1] == '$' {
+			buf.WriteString(s[:i-1])
+			buf.WriteString("${")
+			s = s[i+2:]
+			continue
+		}
+
+		end := strings.Index(s[i:], "}")
+		if end < 0 {
+			return nil, &ErrUnresolved{Key: path, Ref: s[i+2:]}
+		}
+		ref := s[i+2 : i+end]
+		v, err := r.ref(path, ref)
+		if err != nil {
+			return nil, err
+		}
+
+		// 整个字符串仅为一个引用时保留被引用值的类型。
+		if i == 0 && end == len(s)-1 && buf.Len() == 0 {
+			return copyValue(v), nil
+		}
+
+		switch val := v.(type) {
+		case map[string]interface{}, []interface{}:
+			return nil, typeMismatch(ref, "string", v)
+		case time.Time:
+			buf.WriteString(s[:i] + val.Format(time.RFC3339Nano))
+		default:
+			buf.WriteString(s[:i] + fmt.Sprint(val))
+		}
+		s = s[i+end+1:]
+	}
+}
+
+// 解析一个引用，依次按配置项、环境变量、默认值查找。
+func (r *resolver) ref(path, ref string) (interface{}, error) {
+	name, def, hasDef := ref, "", false
+	if i := strings.Index(ref, ":"); i >= 0 {
+		name, def, hasDef = ref[:i], ref[i+1:], true
+	}
+
+	if name != "" {
+		if s, ok := r.c.lookupOverride(name); ok {
+			r.taint(path, name, s)
+			return s, nil
+		}
+		if v, ok := lookup(r.data, name); ok {
+			nv, err := r.node(name, v)
+			if err != nil {
+				return nil, err
+			}
+			setValue(r.data, name, nv)
+			r.taint(path, name, nv)
+			return nv, nil
+		}
+		if s, ok := os.LookupEnv(name); ok {
+			return s, nil
+		}
+	}
+	if hasDef {
+		return def, nil
+	}
+	return nil, &ErrUnresolved{Key: path, Ref: ref}
+}
+
+// path引用了配置项name，name为敏感配置项时将path记为敏感配置项；
+// name为表时，其中的敏感配置项复制到path下对应的配置键。
+func (r *resolver) taint(path, name string, v interface{}) {
+	if r.c.isSecret(r.snap, name) {
+		r.snap.secrets[path] = true
+		return
+	}
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, sub := range val {
+			r.taint(joinKey(path, k), joinKey(name, k), sub)
+		}
+	case []interface{}:
+		for i, sub := range val {
+			if isTable(sub) {
+				r.taint(fmt.Sprintf("%s[%d]", path, i), fmt.Sprintf("%s[%d]", name, i), sub)
+			}
+		}
+	}
+}
+
+// 按"."分隔的键路径设置已存在的配置项。
+func setValue(data map[string]interface{}, key string, v interface{}) {
+	i := strings.LastIndex(key, ".")
+	if i < 0 {
+		data[key] = v
+		return
+	}
+	if parent, ok := lookup(data, key[:i]); ok {
+		if m, ok := parent.(map[string]interface{}); ok {
+			m[key[i+1:]] = v
+		}
+	}
+}
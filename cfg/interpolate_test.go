@@ -0,0 +1,62 @@
+package cfg
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	os.Setenv("CFG_TEST_HOME", "/home/svc")
+	defer os.Unsetenv("CFG_TEST_HOME")
+
+	dir := tempDir(t)
+	c, err := Load(writeFile(t, dir, "a.toml", `
+host = "db.local"
+root = "${CFG_TEST_HOME}/data"
+literal = "cost: $${price}"
+[defaults]
+maxlink = 20
+[mongo]
+servers = "${host}:27017;${host}:27018"
+maxlink = "${defaults.maxlink}"
+logdir = "${CFG_TEST_MISSING:/var/log}/${mongo.name}"
+name = "${app.name}"
+[app]
+name = "air"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		"mongo.servers": "db.local:27017;db.local:27018",
+		"root":          "/home/svc/data",
+		"literal":       "cost: ${price}",
+		"mongo.logdir":  "/var/log/air",
+	}
+	for key, want := range cases {
+		if got := c.GetString(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	if v, err := c.GetIntE("mongo.maxlink"); err != nil || v != 20 {
+		t.Errorf("whole reference should keep type, got %v %v", v, err)
+	}
+}
+
+func TestInterpolateErrors(t *testing.T) {
+	dir := tempDir(t)
+
+	_, err := Load(writeFile(t, dir, "cycle.toml", "a = \"${b}\"\nb = \"x${c.d}\"\n[c]\nd = \"${a}\"\n"))
+	var ce *ErrCycle
+	if !errors.As(err, &ce) || len(ce.Keys) != 4 || ce.Keys[0] != ce.Keys[3] {
+		t.Errorf("expected cycle, got %v", err)
+	}
+
+	_, err = Load(writeFile(t, dir, "missing.toml", "[mongo]\nhost = \"${nothing.here}\"\n"))
+	var ue *ErrUnresolved
+	if !errors.As(err, &ue) || ue.Key != "mongo.host" || ue.Ref != "nothing.here" {
+		t.Errorf("expected unresolved, got %v", err)
+	}
+}
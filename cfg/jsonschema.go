@@ -0,0 +1,280 @@
+package cfg
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/betterjun/pkg/validation"
+)
+
+// 生成的JSON Schema文档遵循的版本。
+const jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// Go时长字符串，如"1m30s"，或表示秒数的整数字符串。
+const durationPattern = `^-?([0-9]+|([0-9]+(\.[0-9]*)?(ns|us|µs|ms|s|m|h))+)$`
+
+// 由配置结构生成JSON Schema文档（draft-07），v为结构体指针，供编辑器补全和检查配置文件。
+//
+// 属性名、类型和默认值的规则同GenerateTemplate，desc标签生成description。
+// valid标签中的规则尽量转换为对应的关键字：Required生成required，字符串同时要求非空；
+// Min、Max、Range生成minimum、maximum；MinLength、MaxLength、Length按类型生成
+// minLength、maxLength或minItems、maxItems；Match、NoMatch生成pattern；
+// Email、IP生成format。其他规则没有对应的关键字，不出现在文档中。
+// 时长既可以是字符串也可以是表示秒数的整数，时间为date-time格式的字符串。
+func GenerateJSONSchema(v interface{}) ([]byte, error) {
+	rv, err := schemaStruct(v)
+	if err != nil {
+		return nil, err
+	}
+	s, err := structSchema(rv)
+	if err != nil {
+		return nil, err
+	}
+	s["$schema"] = jsonSchemaDraft
+	return marshalSchema(s)
+}
+
+// 由多个配置结构生成一份JSON Schema文档，sections为配置键 -> 结构体指针，
+// 如cfgctl.Main的参数，配置键可以包含"."表示嵌套的表。
+func GenerateJSONSchemaSections(sections map[string]interface{}) ([]byte, error) {
+	keys := make([]string, 0, len(sections))
+	for k := range sections {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	root := objectSchema()
+	for _, key := range keys {
+		rv, err := schemaStruct(sections[key])
+		if err != nil {
+			return nil, fmt.Errorf("cfg: %s: %v", key, err)
+		}
+		s, err := structSchema(rv)
+		if err != nil {
+			return nil, err
+		}
+
+		parent := root
+		parts := strings.Split(key, ".")
+		for _, p := range parts[:len(parts)-1] {
+			props := parent["properties"].(map[string]interface{})
+			sub, ok := props[p].(map[string]interface{})
+			if !ok {
+				sub = objectSchema()
+				props[p] = sub
+			}
+			parent = sub
+		}
+		parent["properties"].(map[string]interface{})[parts[len(parts)-1]] = s
+	}
+	root["$schema"] = jsonSchemaDraft
+	return marshalSchema(root)
+}
+
+func schemaStruct(v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return rv, fmt.Errorf("cfg: JSON Schema requires a struct, got %T", v)
+	}
+	return rv, nil
+}
+
+func marshalSchema(s map[string]interface{}) ([]byte, error) {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+func objectSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+}
+
+// 结构体对应的模式，未指定标签的匿名结构体字段与外层合并。
+func structSchema(v reflect.Value) (map[string]interface{}, error) {
+	s := objectSchema()
+	props := s["properties"].(map[string]interface{})
+	var required []string
+	if err := addProperties(props, &required, v); err != nil {
+		return nil, err
+	}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s, nil
+}
+
+func addProperties(props map[string]interface{}, required *[]string, v reflect.Value) error {
+	return eachField(v, false, func(name string, f reflect.StructField, fv reflect.Value) error {
+		s, req, err := fieldSchema(f, fv)
+		if err != nil {
+			return fmt.Errorf("cfg: %s: %v", name, err)
+		}
+		props[name] = s
+		if req {
+			*required = append(*required, name)
+		}
+		return nil
+	})
+}
+
+// 字段对应的模式，req表示字段有Required规则。
+func fieldSchema(f reflect.StructField, fv reflect.Value) (s map[string]interface{}, req bool, err error) {
+	ft := f.Type
+	if ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+		if fv.IsNil() {
+			fv = reflect.Zero(ft)
+		} else {
+			fv = fv.Elem()
+		}
+	}
+	if s, err = typeSchema(ft, fv); err != nil {
+		return nil, false, err
+	}
+	if desc := f.Tag.Get("desc"); desc != "" {
+		s["description"] = desc
+	}
+
+	// 表和表数组的默认值由成员的模式给出
+	if !isStructType(ft) && !(ft.Kind() == reflect.Slice && isStructType(ft.Elem())) {
+		var def interface{}
+		if tag, ok := f.Tag.Lookup("default"); ok && fv.IsZero() {
+			def, err = parseTagDefault(ft, tag)
+		} else if !fv.IsZero() {
+			def, err = templateValue(fv)
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if def != nil {
+			s["default"] = jsonValue(def)
+		}
+	}
+
+	rules, err := validation.ParseTag(f.Tag.Get(validation.ValidTag))
+	if err != nil {
+		return nil, false, fmt.Errorf("valid: %v", err)
+	}
+	for _, r := range rules {
+		if r.Name == "Required" {
+			req = true
+		}
+		applyRule(s, ft, r)
+	}
+	return s, req, nil
+}
+
+// 类型对应的模式，v用于结构体字段的默认值。
+func typeSchema(t reflect.Type, v reflect.Value) (map[string]interface{}, error) {
+	switch t {
+	case durationType:
+		return map[string]interface{}{"type": []string{"string", "integer"}, "pattern": durationPattern}, nil
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}, nil
+	case reflect.Interface:
+		return map[string]interface{}{}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := elemSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			break
+		}
+		elem, err := elemSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": elem}, nil
+	case reflect.Struct:
+		return structSchema(v)
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+func elemSchema(t reflect.Type) (map[string]interface{}, error) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return typeSchema(t, reflect.Zero(t))
+}
+
+// 将valid标签中的规则转换为模式关键字。
+func applyRule(s map[string]interface{}, t reflect.Type, r validation.Rule) {
+	isArray := t.Kind() == reflect.Slice || t.Kind() == reflect.Array
+	minLen, maxLen := "minLength", "maxLength"
+	if isArray {
+		minLen, maxLen = "minItems", "maxItems"
+	}
+	switch r.Name {
+	case "Required":
+		if t.Kind() == reflect.String {
+			s["minLength"] = 1
+		}
+	case "Min":
+		s["minimum"] = r.Ints[0]
+	case "Max":
+		s["maximum"] = r.Ints[0]
+	case "Range":
+		s["minimum"], s["maximum"] = r.Ints[0], r.Ints[1]
+	case "MinLength":
+		s[minLen] = r.Ints[0]
+	case "MaxLength":
+		s[maxLen] = r.Ints[0]
+	case "Length":
+		s[minLen], s[maxLen] = r.Ints[0], r.Ints[0]
+	case "Match":
+		s["pattern"] = r.Args[0]
+	case "NoMatch":
+		s["not"] = map[string]interface{}{"pattern": r.Args[0]}
+	case "Email":
+		s["format"] = "email"
+	case "IP":
+		s["format"] = "ipv4"
+	}
+}
+
+// 将配置数据转换为JSON中的值，时间转换为RFC3339格式的字符串。
+func jsonValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	case []interface{}:
+		arr := make([]interface{}, len(val))
+		for i, e := range val {
+			arr[i] = jsonValue(e)
+		}
+		return arr
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, e := range val {
+			m[k] = jsonValue(e)
+		}
+		return m
+	}
+	return v
+}
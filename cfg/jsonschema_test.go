@@ -0,0 +1,164 @@
+package cfg
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestGenerateJSONSchema(t *testing.T) {
+	b, err := GenerateJSONSchema(&templateSettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s map[string]interface{}
+	if err := json.Unmarshal(b, &s); err != nil {
+		t.Fatal(err)
+	}
+	mongo := s["properties"].(map[string]interface{})["mongo"].(map[string]interface{})
+	maxlink := mongo["properties"].(map[string]interface{})["maxlink"]
+	want := map[string]interface{}{"type": "integer", "default": 10.0, "minimum": 1.0, "maximum": 100.0}
+	if !reflect.DeepEqual(maxlink, want) {
+		t.Errorf("mongo.maxlink = %v", maxlink)
+	}
+	if !reflect.DeepEqual(mongo["required"], []interface{}{"servers"}) || mongo["description"] != "MongoDB" {
+		t.Errorf("mongo = %v", mongo)
+	}
+
+	// 模板生成的配置文件符合生成的模式
+	dir := tempDir(t)
+	c, err := Load(writeFile(t, dir, "sample.toml", templateWant))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ValidateJSONSchema(b); err != nil {
+		t.Errorf("template does not match schema: %v", err)
+	}
+
+	b, err = GenerateJSONSchemaSections(map[string]interface{}{"db.mongo": &templateSettings{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = nil
+	if err := json.Unmarshal(b, &s); err != nil {
+		t.Fatal(err)
+	}
+	db := s["properties"].(map[string]interface{})["db"].(map[string]interface{})
+	if _, ok := db["properties"].(map[string]interface{})["mongo"]; !ok {
+		t.Errorf("sections schema = %s", b)
+	}
+
+	// 时长可以是整数秒数的字符串，如来自环境变量的值
+	re := regexp.MustCompile(durationPattern)
+	for d, ok := range map[string]bool{"1m30s": true, "-1.5h": true, "30": true, "30x": false, "": false} {
+		if re.MatchString(d) != ok {
+			t.Errorf("durationPattern matches %q = %v", d, !ok)
+		}
+	}
+}
+
+const schemaDoc = `{
+  "type": "object",
+  "required": ["name", "mongo"],
+  "properties": {
+    "mongo": {"$ref": "#/definitions/mongo"},
+    "level": {"enum": ["debug", "info"]},
+    "backends": {
+      "type": "array",
+      "items": {"type": "object", "properties": {"port": {"type": "integer", "maximum": 65535}}}
+    },
+    "timeout": {"anyOf": [{"type": "integer"}, {"type": "string", "pattern": "^[0-9]+s$"}]}
+  },
+  "definitions": {
+    "mongo": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "maxlink": {"type": "integer", "minimum": 1, "maximum": 100},
+        "servers": {"type": "string", "minLength": 1}
+      }
+    }
+  }
+}`
+
+func TestValidateJSONSchema(t *testing.T) {
+	dir := tempDir(t)
+	file := writeFile(t, dir, "app.toml", `level = "trace"
+timeout = "5m"
+
+[mongo]
+maxlink = 200
+servers = ""
+sever = "typo"
+
+[[backends]]
+port = 80
+
+[[backends]]
+port = 70000
+`)
+	c, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.ValidateJSONSchema([]byte(schemaDoc))
+	var se *ErrJSONSchema
+	if !errors.As(err, &se) {
+		t.Fatalf("ValidateJSONSchema = %v", err)
+	}
+	var got []string
+	for _, v := range se.Violations {
+		got = append(got, v.String())
+	}
+	want := []string{
+		file + ":13: backends[1].port: must be <= 65535",
+		file + ":1: level: must be one of [\"debug\",\"info\"]",
+		file + ":5: mongo.maxlink: must be <= 100",
+		file + ":6: mongo.servers: length must be >= 1",
+		file + ":7: mongo.sever: is not allowed",
+		"name: is required",
+		file + ":2: timeout: does not match any schema in anyOf",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("violations =\n%q\nwant\n%q", got, want)
+	}
+
+	if err := c.ValidateJSONSchema([]byte(`{"properties": {"x": {"$ref": "#/missing"}}, "$ref": "#/properties/x"}`)); err == nil || errors.As(err, &se) {
+		t.Errorf("bad $ref = %v", err)
+	}
+}
+
+func TestRegisterJSONSchema(t *testing.T) {
+	dir := tempDir(t)
+	file := writeFile(t, dir, "app.toml", "[mongo]\nmaxlink = 10\n")
+	c := New()
+	if err := c.RegisterJSONSchema([]byte("{")); err == nil {
+		t.Error("invalid JSON should fail")
+	}
+	if err := c.RegisterJSONSchema([]byte(`{"properties": {"mongo": {"$ref": "#/definitions/mongo"}}, "definitions": {"mongo": {"properties": {"maxlink": {"maximum": 100}}}}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.LoadConfig(file); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, dir, "app.toml", "[mongo]\nmaxlink = 101\n")
+	var se *ErrJSONSchema
+	if err := c.Reload(); !errors.As(err, &se) {
+		t.Errorf("Reload = %v", err)
+	}
+	if v := c.GetInt("mongo.maxlink"); v != 10 {
+		t.Errorf("invalid config applied, mongo.maxlink = %d", v)
+	}
+
+	// 环境变量覆盖的值报告环境变量
+	writeFile(t, dir, "app.toml", "[mongo]\nmaxlink = 10\n")
+	t.Setenv("APP_MONGO__MAXLINK", "200")
+	c.EnableEnv("APP", "__")
+	if err := c.ValidateJSONSchema([]byte(`{"properties": {"mongo": {"properties": {"maxlink": {"maximum": 100}}}}}`)); !errors.As(err, &se) ||
+		len(se.Violations) != 1 || se.Violations[0].Location.File != "env:APP_MONGO__MAXLINK" {
+		t.Errorf("ValidateJSONSchema = %v", err)
+	}
+}
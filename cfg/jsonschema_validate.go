@@ -0,0 +1,521 @@
+package cfg
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 一处不符合JSON Schema的配置项。
+type Violation struct {
+	Key      string   // 配置键，数组中的项如backends[0].host
+	Location Location // 配置项或其所在表的来源，未知时为零值
+	Message  string
+}
+
+func (v Violation) String() string {
+	key := v.Key
+	if key == "" {
+		key = "(root)"
+	}
+	if v.Location.File == "" {
+		return key + ": " + v.Message
+	}
+	return v.Location.String() + ": " + key + ": " + v.Message
+}
+
+// 配置不符合JSON Schema，Violations按配置键排序。
+type ErrJSONSchema struct {
+	Violations []Violation
+}
+
+func (e *ErrJSONSchema) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.String()
+	}
+	return "cfg: config does not match JSON Schema: " + strings.Join(msgs, "; ")
+}
+
+// 注册JSON Schema文档，此后每次载入配置时按该文档校验，不通过时返回*ErrJSONSchema，原配置保持不变。
+// 文档不是合法的JSON时返回错误。校验规则见ValidateJSONSchema。
+func (c *Config) RegisterJSONSchema(schema []byte) error {
+	s, err := parseJSONSchema(schema)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.jsonSchemas = append(c.jsonSchemas, s)
+	c.mu.Unlock()
+	return nil
+}
+
+// 按JSON Schema文档校验当前生效的配置，已应用命令行参数和环境变量覆盖。
+// 不通过时返回*ErrJSONSchema，列出每处错误的配置键和来源行号。
+//
+// 支持type、enum、const、properties、required、additionalProperties、patternProperties、
+// items、minItems、maxItems、uniqueItems、minimum、maximum、exclusiveMinimum、exclusiveMaximum、
+// multipleOf、minLength、maxLength、pattern、format（date-time、email、ipv4、ipv6）、
+// allOf、anyOf、oneOf、not以及文档内的$ref，其他关键字忽略。
+// TOML日期时间视为字符串。
+func (c *Config) ValidateJSONSchema(schema []byte) error {
+	s, err := parseJSONSchema(schema)
+	if err != nil {
+		return err
+	}
+	snap := c.getSnapshot()
+	if snap == nil {
+		return ErrNotLoaded
+	}
+	return c.checkJSONSchema(snap, s)
+}
+
+func parseJSONSchema(schema []byte) (interface{}, error) {
+	var s interface{}
+	if err := json.Unmarshal(schema, &s); err != nil {
+		return nil, fmt.Errorf("cfg: JSON Schema: %v", err)
+	}
+	return s, nil
+}
+
+// 按注册的JSON Schema文档校验快照。
+func (c *Config) checkJSONSchemas(snap *snapshot) error {
+	c.mu.Lock()
+	schemas := c.jsonSchemas
+	c.mu.Unlock()
+
+	for _, s := range schemas {
+		if err := c.checkJSONSchema(snap, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Config) checkJSONSchema(snap *snapshot, schema interface{}) error {
+	sc := &schemaChecker{root: schema, source: func(key string) (Location, bool) { return c.source(snap, key) }}
+	sc.check("", c.overlay("", snap.data), schema, 0)
+	if sc.err != nil {
+		return sc.err
+	}
+	if len(sc.violations) > 0 {
+		sort.SliceStable(sc.violations, func(i, j int) bool {
+			return sc.violations[i].Key < sc.violations[j].Key
+		})
+		return &ErrJSONSchema{Violations: sc.violations}
+	}
+	return nil
+}
+
+// $ref的最大嵌套深度，避免循环引用。
+const maxSchemaDepth = 64
+
+type schemaChecker struct {
+	root       interface{}
+	source     func(key string) (Location, bool) // 配置键的来源，见Config.Source
+	violations []Violation
+	err        error // 文档本身的错误
+}
+
+func (sc *schemaChecker) fail(key, format string, args ...interface{}) {
+	sc.violations = append(sc.violations, Violation{Key: key, Location: sc.locate(key), Message: fmt.Sprintf(format, args...)})
+}
+
+// 返回配置键的来源，包括命令行参数和环境变量，不存在时依次使用上级配置键的来源。
+func (sc *schemaChecker) locate(key string) Location {
+	for key != "" {
+		if loc, ok := sc.source(key); ok {
+			return loc
+		}
+		i := strings.LastIndexAny(key, ".[")
+		if i < 0 {
+			break
+		}
+		key = key[:i]
+	}
+	return Location{}
+}
+
+func (sc *schemaChecker) schemaError(format string, args ...interface{}) {
+	if sc.err == nil {
+		sc.err = fmt.Errorf("cfg: JSON Schema: "+format, args...)
+	}
+}
+
+// 判断v是否符合模式，不记录错误。
+func (sc *schemaChecker) matches(key string, v, schema interface{}, depth int) bool {
+	sub := &schemaChecker{root: sc.root, source: sc.source}
+	sub.check(key, v, schema, depth)
+	if sub.err != nil {
+		sc.schemaError("%v", sub.err)
+	}
+	return len(sub.violations) == 0
+}
+
+func (sc *schemaChecker) check(key string, v, schema interface{}, depth int) {
+	if depth > maxSchemaDepth {
+		sc.schemaError("$ref nested too deeply")
+		return
+	}
+	var s map[string]interface{}
+	switch val := schema.(type) {
+	case bool:
+		if !val {
+			sc.fail(key, "not allowed")
+		}
+		return
+	case map[string]interface{}:
+		s = val
+	default:
+		sc.schemaError("schema must be an object or boolean")
+		return
+	}
+
+	if ref, ok := s["$ref"].(string); ok {
+		target, err := sc.resolve(ref)
+		if err != nil {
+			sc.schemaError("%v", err)
+			return
+		}
+		sc.check(key, v, target, depth+1)
+		return
+	}
+
+	if t, ok := s["type"]; ok && !matchType(v, t) {
+		sc.fail(key, "expected %s, got %s", formatTypes(t), jsonType(v))
+		return
+	}
+	if enum, ok := s["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			found = found || jsonEqual(v, e)
+		}
+		if !found {
+			sc.fail(key, "must be one of %s", formatJSON(enum))
+		}
+	}
+	if c, ok := s["const"]; ok && !jsonEqual(v, c) {
+		sc.fail(key, "must be %s", formatJSON(c))
+	}
+
+	switch val := v.(type) {
+	case int64, float64:
+		sc.checkNumber(key, toFloat(val), s)
+	case string:
+		sc.checkString(key, val, s)
+	case time.Time:
+		sc.checkString(key, val.Format(time.RFC3339Nano), s)
+	case []interface{}:
+		sc.checkArray(key, val, s, depth)
+	case map[string]interface{}:
+		sc.checkObject(key, val, s, depth)
+	}
+
+	if all, ok := s["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			sc.check(key, v, sub, depth+1)
+		}
+	}
+	if anyOf, ok := s["anyOf"].([]interface{}); ok {
+		found := false
+		for _, sub := range anyOf {
+			if sc.matches(key, v, sub, depth+1) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			sc.fail(key, "does not match any schema in anyOf")
+		}
+	}
+	if one, ok := s["oneOf"].([]interface{}); ok {
+		n := 0
+		for _, sub := range one {
+			if sc.matches(key, v, sub, depth+1) {
+				n++
+			}
+		}
+		if n != 1 {
+			sc.fail(key, "must match exactly one schema in oneOf, matched %d", n)
+		}
+	}
+	if not, ok := s["not"]; ok && sc.matches(key, v, not, depth+1) {
+		sc.fail(key, "must not match the schema in not")
+	}
+}
+
+// 解析文档内的引用，如"#/definitions/mongo"。
+func (sc *schemaChecker) resolve(ref string) (interface{}, error) {
+	if ref == "#" {
+		return sc.root, nil
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported $ref %q", ref)
+	}
+	cur := sc.root
+	for _, p := range strings.Split(ref[2:], "/") {
+		p = strings.Replace(strings.Replace(p, "~1", "/", -1), "~0", "~", -1)
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("$ref %q not found", ref)
+		}
+		if cur, ok = m[p]; !ok {
+			return nil, fmt.Errorf("$ref %q not found", ref)
+		}
+	}
+	return cur, nil
+}
+
+func (sc *schemaChecker) checkNumber(key string, n float64, s map[string]interface{}) {
+	if min, ok := s["minimum"].(float64); ok && n < min {
+		sc.fail(key, "must be >= %v", min)
+	}
+	if max, ok := s["maximum"].(float64); ok && n > max {
+		sc.fail(key, "must be <= %v", max)
+	}
+	if min, ok := s["exclusiveMinimum"].(float64); ok && n <= min {
+		sc.fail(key, "must be > %v", min)
+	}
+	if max, ok := s["exclusiveMaximum"].(float64); ok && n >= max {
+		sc.fail(key, "must be < %v", max)
+	}
+	if m, ok := s["multipleOf"].(float64); ok && m > 0 {
+		if q := n / m; q != math.Trunc(q) {
+			sc.fail(key, "must be a multiple of %v", m)
+		}
+	}
+}
+
+func (sc *schemaChecker) checkString(key, str string, s map[string]interface{}) {
+	n := float64(utf8.RuneCountInString(str))
+	if min, ok := s["minLength"].(float64); ok && n < min {
+		sc.fail(key, "length must be >= %v", min)
+	}
+	if max, ok := s["maxLength"].(float64); ok && n > max {
+		sc.fail(key, "length must be <= %v", max)
+	}
+	if p, ok := s["pattern"].(string); ok {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			sc.schemaError("pattern %q: %v", p, err)
+		} else if !re.MatchString(str) {
+			sc.fail(key, "must match pattern %q", p)
+		}
+	}
+	if f, ok := s["format"].(string); ok && !checkFormat(f, str) {
+		sc.fail(key, "must be a valid %s", f)
+	}
+}
+
+func checkFormat(format, s string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339Nano, s)
+		return err == nil
+	case "email":
+		i := strings.LastIndex(s, "@")
+		return i > 0 && i < len(s)-1 && !strings.ContainsAny(s, " \t")
+	case "ipv4":
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() != nil && !strings.Contains(s, ":")
+	case "ipv6":
+		ip := net.ParseIP(s)
+		return ip != nil && strings.Contains(s, ":")
+	}
+	return true
+}
+
+func (sc *schemaChecker) checkArray(key string, arr []interface{}, s map[string]interface{}, depth int) {
+	n := float64(len(arr))
+	if min, ok := s["minItems"].(float64); ok && n < min {
+		sc.fail(key, "must have at least %v items", min)
+	}
+	if max, ok := s["maxItems"].(float64); ok && n > max {
+		sc.fail(key, "must have at most %v items", max)
+	}
+	if u, ok := s["uniqueItems"].(bool); ok && u {
+		for i := range arr {
+			for j := 0; j < i; j++ {
+				if jsonEqual(arr[i], arr[j]) {
+					sc.fail(fmt.Sprintf("%s[%d]", key, i), "duplicates item %d", j)
+				}
+			}
+		}
+	}
+	switch items := s["items"].(type) {
+	case []interface{}:
+		for i, e := range arr {
+			if i < len(items) {
+				sc.check(fmt.Sprintf("%s[%d]", key, i), e, items[i], depth+1)
+			}
+		}
+	case nil:
+	default:
+		for i, e := range arr {
+			sc.check(fmt.Sprintf("%s[%d]", key, i), e, items, depth+1)
+		}
+	}
+}
+
+func (sc *schemaChecker) checkObject(key string, m map[string]interface{}, s map[string]interface{}, depth int) {
+	if req, ok := s["required"].([]interface{}); ok {
+		for _, r := range req {
+			name, _ := r.(string)
+			if _, ok := m[name]; !ok {
+				sc.fail(joinKey(key, name), "is required")
+			}
+		}
+	}
+
+	props, _ := s["properties"].(map[string]interface{})
+	patterns, _ := s["patternProperties"].(map[string]interface{})
+	names := make([]string, 0, len(m))
+	for k := range m {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		k := joinKey(key, name)
+		matched := false
+		if p, ok := props[name]; ok {
+			sc.check(k, m[name], p, depth+1)
+			matched = true
+		}
+		for pattern, p := range patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				sc.schemaError("patternProperties %q: %v", pattern, err)
+				continue
+			}
+			if re.MatchString(name) {
+				sc.check(k, m[name], p, depth+1)
+				matched = true
+			}
+		}
+		if matched {
+			continue
+		}
+		switch ap := s["additionalProperties"].(type) {
+		case bool:
+			if !ap {
+				sc.fail(k, "is not allowed")
+			}
+		case map[string]interface{}:
+			sc.check(k, m[name], ap, depth+1)
+		}
+	}
+}
+
+// 返回配置值对应的JSON类型。
+func jsonType(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case int64:
+		return "integer"
+	case float64:
+		if val == math.Trunc(val) && !math.IsInf(val, 0) {
+			return "integer"
+		}
+		return "number"
+	case string, time.Time:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func matchType(v interface{}, t interface{}) bool {
+	switch val := t.(type) {
+	case string:
+		got := jsonType(v)
+		return got == val || (val == "number" && got == "integer")
+	case []interface{}:
+		for _, e := range val {
+			if matchType(v, e) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func formatTypes(t interface{}) string {
+	if arr, ok := t.([]interface{}); ok {
+		s := make([]string, len(arr))
+		for i, e := range arr {
+			s[i] = fmt.Sprint(e)
+		}
+		return strings.Join(s, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+func formatJSON(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// 比较配置值与JSON中的值，数字按数值比较。
+func jsonEqual(a, b interface{}) bool {
+	switch val := a.(type) {
+	case int64, float64:
+		if f, ok := b.(float64); ok {
+			return toFloat(val) == f
+		}
+		if n, ok := b.(int64); ok {
+			return toFloat(val) == float64(n)
+		}
+		return false
+	case time.Time:
+		return jsonEqual(val.Format(time.RFC3339Nano), b)
+	case []interface{}:
+		arr, ok := b.([]interface{})
+		if !ok || len(arr) != len(val) {
+			return false
+		}
+		for i := range val {
+			if !jsonEqual(val[i], arr[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		m, ok := b.(map[string]interface{})
+		if !ok || len(m) != len(val) {
+			return false
+		}
+		for k, e := range val {
+			if !jsonEqual(e, m[k]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
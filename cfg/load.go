@@ -0,0 +1,297 @@
+package cfg
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// 数组合并策略。
+const (
+	ArrayReplace = iota // 后载入的文件整体替换数组
+	ArrayAppend         // 后载入的文件追加到数组末尾
+)
+
+// 设置多个配置文件合并时数组的合并策略，下次载入时生效。
+func (c *Config) SetArrayPolicy(policy int) {
+	atomic.StoreInt32(&c.arrayPolicy, int32(policy))
+}
+
+// 配置项来源位置。
+type Location struct {
+	// 提供该值的文件，来自环境变量时为"env:变量名"，来自命令行参数时为"flag:--参数名"，
+	// 由Override设置时为"override"。
+	File string
+	Line int // 所在行号，未知时为0
+}
+
+func (l Location) String() string {
+	if l.Line <= 0 {
+		return l.File
+	}
+	return fmt.Sprintf("%s:%d", l.File, l.Line)
+}
+
+// 返回配置项生效值的来源，配置项不存在时第二个返回值为false。
+func (c *Config) Source(key string) (Location, bool) {
+	return c.source(c.getSnapshot(), key)
+}
+
+// 返回配置键在snap中的来源，命令行参数和环境变量优先。
+func (c *Config) source(snap *snapshot, key string) (Location, bool) {
+	if _, ok := c.lookupFlag(key); ok {
+		return Location{File: "flag:--" + key}, true
+	}
+	if e := c.getEnvOverlay(); e != nil {
+		if _, ok := c.lookupEnv(key); ok {
+			return Location{File: "env:" + e.name(key)}, true
+		}
+	}
+
+	if snap == nil {
+		return Location{}, false
+	}
+	s, ok := snap.sources[key]
+	return s, ok
+}
+
+// 载入后的配置快照，载入后不再修改。
+type snapshot struct {
+	files     []string               // 按顺序载入的文件，包括被包含的文件
+	globs     []string               // include中的文件模式，已转为相对于工作目录的路径
+	data      map[string]interface{} // 合并后的配置数据
+	sources   map[string]Location    // 配置键 -> 来源
+	encrypted map[string]bool        // 值经过解密的配置键
+	secrets   map[string]bool        // 值中引用了敏感配置项的配置键
+	profile   string                 // 生效的配置方案
+	profiles  map[string]bool        // 配置文件中定义的配置方案
+}
+
+// 按顺序载入并合并多个配置文件，后面的文件覆盖前面的文件，远程配置覆盖所有文件。
+func (c *Config) loadFiles(files []string, remotes []remoteDoc) (*snapshot, error) {
+	snap := &snapshot{
+		data:      make(map[string]interface{}),
+		sources:   make(map[string]Location),
+		encrypted: make(map[string]bool),
+		secrets:   make(map[string]bool),
+		profile:   c.selectedProfile(),
+		profiles:  make(map[string]bool),
+	}
+	policy := int(atomic.LoadInt32(&c.arrayPolicy))
+	for _, file := range files {
+		if err := c.loadLayer(snap, file, nil, policy); err != nil {
+			return nil, err
+		}
+	}
+	if err := mergeRemotes(snap, remotes, policy); err != nil {
+		return nil, err
+	}
+	if err := c.mergeOverrides(snap); err != nil {
+		return nil, err
+	}
+	if err := checkProfile(snap); err != nil {
+		return nil, err
+	}
+	if err := c.decrypt(snap); err != nil {
+		return nil, err
+	}
+	if err := c.interpolate(snap); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// 包含其他配置文件的顶层配置键。
+const includeKey = "include"
+
+// 载入并合并一个文件，再按顺序合并它包含的文件，stack为包含链，用于检测循环包含。
+//
+// 文件中选中的配置方案覆盖该文件的基础配置项，见SetProfile。
+// 文件中顶层的include = ["conf.d/*.toml"]指定包含的文件，相对路径相对于当前文件所在目录，
+// 文件模式匹配的多个文件按文件名排序。被包含的文件如同在当前文件之后依次载入，
+// 覆盖当前文件中的同名配置项，数组按SetArrayPolicy设置的策略合并。
+func (c *Config) loadLayer(snap *snapshot, file string, stack []string, policy int) error {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+	for i, f := range stack {
+		if f == abs {
+			return &ErrIncludeCycle{Files: append(stack[i:len(stack):len(stack)], abs)}
+		}
+	}
+
+	doc, err := c.loadFile(file)
+	if err != nil {
+		return err
+	}
+	includes, err := includeList(doc, file)
+	if err != nil {
+		return err
+	}
+	if err := mergeDoc(snap, doc, file, policy); err != nil {
+		return err
+	}
+	snap.files = append(snap.files, file)
+
+	stack = append(stack[:len(stack):len(stack)], abs)
+	for _, pattern := range includes {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(file), pattern)
+		}
+		if !hasMeta(pattern) {
+			if err := c.loadLayer(snap, pattern, stack, policy); err != nil {
+				return err
+			}
+			continue
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("cfg: %s: include %q: %v", file, pattern, err)
+		}
+		sort.Strings(matches)
+		snap.globs = append(snap.globs, pattern)
+		for _, m := range matches {
+			if err := c.loadLayer(snap, m, stack, policy); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 取出文件中的include配置项，值为字符串或字符串数组。
+func includeList(doc *Document, file string) ([]string, error) {
+	v, ok := doc.Values[includeKey]
+	if !ok {
+		return nil, nil
+	}
+	delete(doc.Values, includeKey)
+
+	switch val := v.(type) {
+	case string:
+		return []string{val}, nil
+	case []interface{}:
+		list := make([]string, len(val))
+		for i, e := range val {
+			s, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("cfg: %s: %v", file, typeMismatch(fmt.Sprintf("%s[%d]", includeKey, i), "string", e))
+			}
+			list[i] = s
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("cfg: %s: %v", file, typeMismatch(includeKey, "array", v))
+}
+
+// 判断路径中是否含有文件模式的特殊字符。
+func hasMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// 将一个文档及其中选中的配置方案合并到快照中，file为文档来源。
+func mergeDoc(snap *snapshot, doc *Document, file string, policy int) error {
+	profile, err := splitProfile(snap, doc, file)
+	if err != nil {
+		return err
+	}
+	merge(snap, "", snap.data, doc.Values, doc, file, policy)
+	if profile != nil {
+		merge(snap, "", snap.data, profile.Values, profile, file, policy)
+	}
+	return nil
+}
+
+// 将文件内容src合并到dst中，path为dst对应的配置键。
+func merge(snap *snapshot, path string, dst, src map[string]interface{}, doc *Document, file string, policy int) {
+	for k, v := range src {
+		key := joinKey(path, k)
+		loc := Location{File: file, Line: doc.Lines[key]}
+
+		if sub, ok := v.(map[string]interface{}); ok {
+			if m, ok := dst[k].(map[string]interface{}); ok {
+				merge(snap, key, m, sub, doc, file, policy)
+				continue
+			}
+			if _, ok := dst[k]; ok {
+				forget(snap, key)
+			}
+			m := make(map[string]interface{})
+			dst[k] = m
+			snap.sources[key] = loc
+			merge(snap, key, m, sub, doc, file, policy)
+			continue
+		}
+
+		base := 0
+		if policy == ArrayAppend {
+			old, ok1 := dst[k].([]interface{})
+			arr, ok2 := v.([]interface{})
+			if ok1 && ok2 {
+				v, base = append(old[:len(old):len(old)], arr...), len(old)
+			}
+		}
+		if _, ok := dst[k]; ok && base == 0 {
+			forget(snap, key)
+		}
+		dst[k] = v
+		snap.sources[key] = loc
+		if arr, ok := src[k].([]interface{}); ok {
+			for i, e := range arr {
+				record(snap, fmt.Sprintf("%s[%d]", key, base+i), fmt.Sprintf("%s[%d]", key, i), e, doc, file)
+			}
+		}
+	}
+}
+
+// 记录数组中各项的来源，path为合并后的配置键，docPath为该项在文件中的配置键。
+func record(snap *snapshot, path, docPath string, v interface{}, doc *Document, file string) {
+	if line, ok := doc.Lines[docPath]; ok {
+		snap.sources[path] = Location{File: file, Line: line}
+	}
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, e := range val {
+			key, docKey := joinKey(path, k), joinKey(docPath, k)
+			snap.sources[key] = Location{File: file, Line: doc.Lines[docKey]}
+			record(snap, key, docKey, e, doc, file)
+		}
+	case []interface{}:
+		for i, e := range val {
+			record(snap, fmt.Sprintf("%s[%d]", path, i), fmt.Sprintf("%s[%d]", docPath, i), e, doc, file)
+		}
+	}
+}
+
+// 删除配置键及其下级键的来源记录。
+func forget(snap *snapshot, key string) {
+	delete(snap.sources, key)
+	for k := range snap.sources {
+		if strings.HasPrefix(k, key+".") || strings.HasPrefix(k, key+"[") {
+			delete(snap.sources, k)
+		}
+	}
+}
+
+// 按"."分隔的键路径在配置数据中查找，key为空串时返回整个配置。
+func lookup(data map[string]interface{}, key string) (interface{}, bool) {
+	if key == "" {
+		return data, true
+	}
+
+	var cur interface{} = data
+	for _, k := range strings.Split(key, ".") {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[k]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
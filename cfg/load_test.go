@@ -0,0 +1,72 @@
+package cfg
+
+import (
+	"reflect"
+	"testing"
+)
+
+const baseToml = `[mongo]
+servers = "base:27017"
+maxlink = 10
+hosts = ["a", "b"]
+
+[log]
+level = "info"
+`
+
+const prodToml = `
+[mongo]
+servers = "prod:27017"
+hosts = ["c"]
+
+[[backends]]
+host = "x"
+`
+
+func TestLayeredLoad(t *testing.T) {
+	dir := tempDir(t)
+	base := writeFile(t, dir, "base.toml", baseToml)
+	prod := writeFile(t, dir, "prod.toml", prodToml)
+	local := writeFile(t, dir, "local.toml", "[log]\nlevel = \"debug\"\n")
+
+	if err := LoadConfig(base, prod, local); err != nil {
+		t.Fatal(err)
+	}
+	if v := GetString("mongo.servers"); v != "prod:27017" {
+		t.Errorf("mongo.servers = %q", v)
+	}
+	if v := GetInt("mongo.maxlink"); v != 10 {
+		t.Errorf("mongo.maxlink = %d", v)
+	}
+	if v := GetString("log.level"); v != "debug" {
+		t.Errorf("log.level = %q", v)
+	}
+	if v := Get("mongo.hosts"); !reflect.DeepEqual(v, []interface{}{"c"}) {
+		t.Errorf("mongo.hosts = %v", v)
+	}
+
+	cases := map[string]Location{
+		"mongo.servers":    {prod, 3},
+		"mongo.maxlink":    {base, 3},
+		"log.level":        {local, 2},
+		"backends[0].host": {prod, 7},
+		"mongo.hosts":      {prod, 4},
+	}
+	for key, want := range cases {
+		if got, ok := Source(key); !ok || got != want {
+			t.Errorf("Source(%q) = %v, want %v", key, got, want)
+		}
+	}
+	if _, ok := Source("missing"); ok {
+		t.Error("missing key should have no source")
+	}
+
+	SetArrayPolicy(ArrayAppend)
+	defer SetArrayPolicy(ArrayReplace)
+	if err := LoadConfig(base, prod); err != nil {
+		t.Fatal(err)
+	}
+	if v := Get("mongo.hosts"); !reflect.DeepEqual(v, []interface{}{"a", "b", "c"}) {
+		t.Errorf("appended mongo.hosts = %v", v)
+	}
+}
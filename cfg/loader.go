@@ -0,0 +1,442 @@
+package cfg
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/betterjun/go-toml"
+	"gopkg.in/yaml.v3"
+)
+
+// 解析后的配置文件。
+type Document struct {
+	// 配置数据。整数为int64，浮点数为float64，表为map[string]interface{}，数组为[]interface{}。
+	Values map[string]interface{}
+
+	// 配置键 -> 所在行号，数组项的键形如"servers[0].host"，无法确定时可以为空。
+	Lines map[string]int
+}
+
+// 配置文件解析器，将各种格式的文件内容解析为统一的键值树。
+type Loader interface {
+	Load(data []byte) (*Document, error)
+}
+
+var (
+	loadersMu sync.RWMutex
+
+	// 扩展名 -> 解析器，扩展名为小写并带"."。
+	loaders = map[string]Loader{
+		".toml": TOMLLoader{},
+		".yaml": YAMLLoader{},
+		".yml":  YAMLLoader{},
+		".json": JSONLoader{},
+		".ini":  INILoader{},
+		".env":  DotenvLoader{Sep: "__"},
+	}
+)
+
+// 注册扩展名对应的解析器，ext如".hcl"，已存在时替换。
+func RegisterLoader(ext string, l Loader) {
+	loadersMu.Lock()
+	defer loadersMu.Unlock()
+	loaders[strings.ToLower(ext)] = l
+}
+
+// 返回文件对应的解析器，未知扩展名按TOML解析。
+func loaderFor(file string) Loader {
+	loadersMu.RLock()
+	defer loadersMu.RUnlock()
+	if l, ok := loaders[strings.ToLower(filepath.Ext(file))]; ok {
+		return l
+	}
+	return TOMLLoader{}
+}
+
+// 指定所有文件使用的解析器，为nil时按扩展名选择，下次载入时生效。
+func (c *Config) SetLoader(l Loader) {
+	c.mu.Lock()
+	c.loader = l
+	c.mu.Unlock()
+}
+
+// 读取并解析一个配置文件。
+func (c *Config) loadFile(file string) (*Document, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	l := c.loader
+	c.mu.Unlock()
+	if l == nil {
+		l = loaderFor(file)
+	}
+
+	doc, err := l.Load(data)
+	if err != nil {
+		return nil, fmt.Errorf("cfg: %s: %v", file, err)
+	}
+	if doc.Values == nil {
+		doc.Values = make(map[string]interface{})
+	}
+	if doc.Lines == nil {
+		doc.Lines = make(map[string]int)
+	}
+	return doc, nil
+}
+
+// TOML格式解析器。
+type TOMLLoader struct{}
+
+func (TOMLLoader) Load(data []byte) (*Document, error) {
+	t, err := toml.Load(string(data))
+	if err != nil {
+		return nil, err
+	}
+	doc := &Document{Lines: make(map[string]int)}
+	doc.Values = normalize(t).(map[string]interface{})
+	tomlLines(doc.Lines, "", t)
+	return doc, nil
+}
+
+// 记录toml树中各配置键的行号。
+func tomlLines(lines map[string]int, path string, t *toml.TomlTree) {
+	for _, k := range t.Keys() {
+		key := joinKey(path, k)
+		lines[key] = t.GetPosition(k).Line
+		switch v := t.Get(k).(type) {
+		case *toml.TomlTree:
+			tomlLines(lines, key, v)
+		case []*toml.TomlTree:
+			for i, sub := range v {
+				tomlLines(lines, fmt.Sprintf("%s[%d]", key, i), sub)
+			}
+		}
+	}
+}
+
+// JSON格式解析器，不记录行号。
+type JSONLoader struct{}
+
+func (JSONLoader) Load(data []byte) (*Document, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v map[string]interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	values, err := normalizeGeneric("", v)
+	if err != nil {
+		return nil, err
+	}
+	return &Document{Values: values.(map[string]interface{})}, nil
+}
+
+// YAML格式解析器。
+type YAMLLoader struct{}
+
+func (YAMLLoader) Load(data []byte) (*Document, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	doc := &Document{Values: make(map[string]interface{}), Lines: make(map[string]int)}
+	if len(root.Content) == 0 {
+		return doc, nil
+	}
+	v, err := yamlValue(doc.Lines, "", root.Content[0])
+	if err != nil {
+		return nil, err
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("top level must be a mapping")
+	}
+	doc.Values = m
+	return doc, nil
+}
+
+// 将YAML节点转换为通用结构，并记录各配置键的行号。空值的处理同normalizeGeneric。
+func yamlValue(lines map[string]int, path string, n *yaml.Node) (interface{}, error) {
+	switch n.Kind {
+	case yaml.AliasNode:
+		return yamlValue(lines, path, n.Alias)
+	case yaml.MappingNode:
+		m := make(map[string]interface{})
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			kn, vn := n.Content[i], n.Content[i+1]
+			if kn.Tag == "!!merge" {
+				merged, err := yamlValue(lines, path, vn)
+				if err != nil {
+					return nil, err
+				}
+				if mm, ok := merged.(map[string]interface{}); ok {
+					for k, v := range mm {
+						if _, ok := m[k]; !ok {
+							m[k] = v
+						}
+					}
+				}
+				continue
+			}
+			key := joinKey(path, kn.Value)
+			v, err := yamlValue(lines, key, vn)
+			if err != nil {
+				return nil, err
+			}
+			if v == nil {
+				continue
+			}
+			lines[key] = kn.Line
+			m[kn.Value] = v
+		}
+		return m, nil
+	case yaml.SequenceNode:
+		s := make([]interface{}, len(n.Content))
+		for i, e := range n.Content {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			lines[p] = e.Line
+			v, err := yamlValue(lines, p, e)
+			if err != nil {
+				return nil, err
+			}
+			if v == nil {
+				return nil, fmt.Errorf("%s: null in array", p)
+			}
+			s[i] = v
+		}
+		return s, nil
+	}
+
+	var v interface{}
+	if err := n.Decode(&v); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return normalizeGeneric(path, v)
+}
+
+// INI格式解析器。节名中的"."表示嵌套的表，如[mongo.auth]；
+// 值按整数、浮点数、布尔值、字符串的顺序推断类型，带引号的值总是字符串。
+type INILoader struct{}
+
+func (INILoader) Load(data []byte) (*Document, error) {
+	doc := &Document{Values: make(map[string]interface{}), Lines: make(map[string]int)}
+	section := ""
+	table := doc.Values
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+
+		if line[0] == '[' {
+			if line[len(line)-1] != ']' {
+				return nil, fmt.Errorf("line %d: invalid section %q", n, line)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			t, err := makeTable(doc.Values, section)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			table = t
+			doc.Lines[section] = n
+			continue
+		}
+
+		i := strings.IndexAny(line, "=:")
+		if i <= 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", n)
+		}
+		k := strings.TrimSpace(line[:i])
+		table[k] = inferValue(strings.TrimSpace(line[i+1:]))
+		doc.Lines[joinKey(section, k)] = n
+	}
+	return doc, scanner.Err()
+}
+
+// dotenv格式解析器，每行为KEY=VALUE，可以带export前缀。
+// 键名转为小写，Sep分隔各级配置键，如Sep为"__"时MONGO__SERVERS对应mongo.servers；
+// 值的类型推断同INILoader。
+type DotenvLoader struct {
+	Sep string
+}
+
+func (l DotenvLoader) Load(data []byte) (*Document, error) {
+	doc := &Document{Values: make(map[string]interface{}), Lines: make(map[string]int)}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+
+		i := strings.Index(line, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", n)
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		if l.Sep != "" {
+			key = strings.Replace(key, strings.ToLower(l.Sep), ".", -1)
+		}
+
+		value := strings.TrimSpace(line[i+1:])
+		if len(value) > 0 && value[0] != '"' && value[0] != '\'' {
+			if j := strings.Index(value, " #"); j >= 0 {
+				value = strings.TrimSpace(value[:j])
+			}
+		}
+
+		parent, name := "", key
+		if j := strings.LastIndex(key, "."); j >= 0 {
+			parent, name = key[:j], key[j+1:]
+		}
+		t, err := makeTable(doc.Values, parent)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		t[name] = inferValue(value)
+		doc.Lines[key] = n
+	}
+	return doc, scanner.Err()
+}
+
+// 返回键路径对应的表，不存在时创建。
+func makeTable(root map[string]interface{}, key string) (map[string]interface{}, error) {
+	t := root
+	if key == "" {
+		return t, nil
+	}
+	for _, k := range strings.Split(key, ".") {
+		switch v := t[k].(type) {
+		case nil:
+			m := make(map[string]interface{})
+			t[k] = m
+			t = m
+		case map[string]interface{}:
+			t = v
+		default:
+			return nil, fmt.Errorf("key %q is not a table", key)
+		}
+	}
+	return t, nil
+}
+
+// 推断无类型文本值的类型，带引号的值总是字符串。
+func inferValue(s string) interface{} {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		if s[0] == '"' {
+			if u, err := strconv.Unquote(s); err == nil {
+				return u
+			}
+		}
+		return s[1 : len(s)-1]
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	if b, err := strconv.ParseBool(s); err == nil && strings.ToLower(s) == s {
+		return b
+	}
+	return s
+}
+
+// 将JSON、YAML等解析出的值统一为与TOML相同的类型。
+// TOML没有空值，表中的null视为未设置该配置项，数组中的null为错误。
+func normalizeGeneric(path string, v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, e := range val {
+			if e == nil {
+				continue
+			}
+			nv, err := normalizeGeneric(joinKey(path, k), e)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = nv
+		}
+		return m, nil
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, e := range val {
+			if e == nil {
+				continue
+			}
+			ks := fmt.Sprint(k)
+			nv, err := normalizeGeneric(joinKey(path, ks), e)
+			if err != nil {
+				return nil, err
+			}
+			m[ks] = nv
+		}
+		return m, nil
+	case []interface{}:
+		s := make([]interface{}, len(val))
+		for i, e := range val {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			if e == nil {
+				return nil, fmt.Errorf("%s: null in array", p)
+			}
+			nv, err := normalizeGeneric(p, e)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = nv
+		}
+		return s, nil
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i, nil
+		}
+		f, err := val.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid number %s", path, val)
+		}
+		return f, nil
+	case int:
+		return int64(val), nil
+	case int8:
+		return int64(val), nil
+	case int16:
+		return int64(val), nil
+	case int32:
+		return int64(val), nil
+	case uint:
+		return normalizeGeneric(path, uint64(val))
+	case uint8:
+		return int64(val), nil
+	case uint16:
+		return int64(val), nil
+	case uint32:
+		return int64(val), nil
+	case uint64:
+		if val > math.MaxInt64 {
+			return nil, &ErrOverflow{Key: path, Value: math.MaxInt64, Type: "int64"}
+		}
+		return int64(val), nil
+	case float32:
+		return float64(val), nil
+	case nil, string, bool, int64, float64, time.Time:
+		return val, nil
+	}
+	return nil, fmt.Errorf("%s: unsupported value type %T", path, v)
+}
@@ -0,0 +1,175 @@
+package cfg
+
+import (
+	"reflect"
+	"testing"
+)
+
+var formats = map[string]string{
+	"app.toml": `name = "app"
+[mongo]
+servers = "db:27017"
+maxlink = 10
+ratio = 0.5
+debug = true
+hosts = ["a", "b"]
+`,
+	"app.yaml": `name: app
+mongo:
+  servers: "db:27017"
+  maxlink: 10
+  ratio: 0.5
+  debug: true
+  hosts:
+    - a
+    - b
+`,
+	"app.json": `{
+  "name": "app",
+  "mongo": {
+    "servers": "db:27017",
+    "maxlink": 10,
+    "ratio": 0.5,
+    "debug": true,
+    "hosts": ["a", "b"]
+  }
+}`,
+	"app.ini": `name = app
+; 数据库
+[mongo]
+servers = "db:27017"
+maxlink = 10
+ratio = 0.5
+debug = true
+`,
+	"app.env": `NAME=app
+# 数据库
+export MONGO__SERVERS="db:27017"
+MONGO__MAXLINK=10
+MONGO__RATIO=0.5 # 比例
+MONGO__DEBUG=true
+`,
+}
+
+func TestLoaders(t *testing.T) {
+	dir := tempDir(t)
+	for name, content := range formats {
+		c, err := Load(writeFile(t, dir, name, content))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if v := c.GetString("name"); v != "app" {
+			t.Errorf("%s: name = %q", name, v)
+		}
+		if v := c.GetString("mongo.servers"); v != "db:27017" {
+			t.Errorf("%s: mongo.servers = %q", name, v)
+		}
+		if v, err := c.GetInt64E("mongo.maxlink"); v != 10 || err != nil {
+			t.Errorf("%s: mongo.maxlink = %d, %v", name, v, err)
+		}
+		if v, err := c.GetFloat64E("mongo.ratio"); v != 0.5 || err != nil {
+			t.Errorf("%s: mongo.ratio = %v, %v", name, v, err)
+		}
+		if v, err := c.GetBoolE("mongo.debug"); !v || err != nil {
+			t.Errorf("%s: mongo.debug = %v, %v", name, v, err)
+		}
+		if name == "app.ini" || name == "app.env" {
+			continue
+		}
+		if v := c.GetStringSlice("mongo.hosts"); !reflect.DeepEqual(v, []string{"a", "b"}) {
+			t.Errorf("%s: mongo.hosts = %v", name, v)
+		}
+	}
+}
+
+func TestLoaderLines(t *testing.T) {
+	dir := tempDir(t)
+	lines := map[string]int{
+		"app.toml": 3,
+		"app.yaml": 3,
+		"app.ini":  4,
+		"app.env":  3,
+	}
+	for name, line := range lines {
+		file := writeFile(t, dir, name, formats[name])
+		c, err := Load(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := Location{File: file, Line: line}
+		if loc, ok := c.Source("mongo.servers"); !ok || loc != want {
+			t.Errorf("%s: Source = %v, %v, want %v", name, loc, ok, want)
+		}
+	}
+}
+
+func TestLayeredFormats(t *testing.T) {
+	dir := tempDir(t)
+	base := writeFile(t, dir, "base.toml", formats["app.toml"])
+	local := writeFile(t, dir, "local.env", "MONGO__MAXLINK=20\n")
+	c, err := Load(base, local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := c.GetInt("mongo.maxlink"); v != 20 {
+		t.Errorf("mongo.maxlink = %d", v)
+	}
+	if v := c.GetString("mongo.servers"); v != "db:27017" {
+		t.Errorf("mongo.servers = %q", v)
+	}
+}
+
+func TestSetLoader(t *testing.T) {
+	dir := tempDir(t)
+	file := writeFile(t, dir, "app.conf", formats["app.json"])
+	c := New()
+	if err := c.LoadConfig(file); err == nil {
+		t.Error("expected error parsing JSON as TOML")
+	}
+	c.SetLoader(JSONLoader{})
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if v := c.GetInt("mongo.maxlink"); v != 10 {
+		t.Errorf("mongo.maxlink = %d", v)
+	}
+}
+
+func TestLoaderNull(t *testing.T) {
+	dir := tempDir(t)
+	files := map[string]string{
+		"null.json": `{"name": null, "mongo": {"servers": "db", "auth": null}}`,
+		"null.yaml": "name:\nmongo:\n  servers: db\n  auth: ~\n",
+	}
+	for name, content := range files {
+		c, err := Load(writeFile(t, dir, name, content))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if c.Get("name") != nil || c.Get("mongo.auth") != nil {
+			t.Errorf("%s: null should leave the key unset", name)
+		}
+		var s struct {
+			Name  string `toml:"name"`
+			Mongo struct {
+				Servers string            `toml:"servers"`
+				Auth    map[string]string `toml:"auth"`
+			} `toml:"mongo"`
+		}
+		if err := c.UnmarshalAll(&s); err != nil || s.Mongo.Servers != "db" {
+			t.Errorf("%s: %+v, %v", name, s, err)
+		}
+	}
+
+	arrays := map[string]string{
+		"array.json": `{"hosts": ["a", null]}`,
+		"array.yaml": "hosts:\n  - a\n  - null\n",
+	}
+	for name, content := range arrays {
+		if _, err := Load(writeFile(t, dir, name, content)); err == nil {
+			t.Errorf("%s: null in array should fail", name)
+		}
+	}
+}
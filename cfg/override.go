@@ -0,0 +1,118 @@
+package cfg
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// 覆盖值的来源，见Location。
+const overrideSource = "override"
+
+// 代码设置的配置项，见Override。
+type override struct {
+	key   string
+	value interface{}
+}
+
+// 在内存中覆盖配置项key的值并重新载入，返回撤销该覆盖的函数，主要用于测试，见cfgtest包。
+//
+// 覆盖的值位于配置文件和远程配置之上，重新载入后依然有效；命令行参数和环境变量仍然优先。
+// key不存在时新建，上级配置项不是表时返回错误。key不能包含数组下标，如backends[0].host，
+// 需要覆盖整个数组。value的类型规则同GenerateTemplate中字段的值，
+// 如time.Duration保存为"1m30s"。同一key多次覆盖时后者优先，撤销后恢复前者。
+func (c *Config) Override(key string, value interface{}) (restore func() error, err error) {
+	if key == "" {
+		return nil, fmt.Errorf("cfg: override requires a key")
+	}
+	if err := checkTableKey("override", key); err != nil {
+		return nil, err
+	}
+	v, err := templateValue(reflect.ValueOf(&value).Elem())
+	if err != nil {
+		return nil, fmt.Errorf("cfg: override %s: %v", key, err)
+	}
+
+	o := &override{key: key, value: v}
+	c.mu.Lock()
+	c.overrides = append(c.overrides, o)
+	c.mu.Unlock()
+
+	if err := c.Reload(); err != nil {
+		c.removeOverride(o)
+		return nil, err
+	}
+
+	var once sync.Once
+	return func() (err error) {
+		once.Do(func() {
+			c.removeOverride(o)
+			err = c.Reload()
+		})
+		return
+	}, nil
+}
+
+// 检查key只由表的键组成，不包含数组下标。
+func checkTableKey(op, key string) error {
+	if strings.ContainsAny(key, "[]") {
+		return fmt.Errorf("cfg: %s %s: array elements are not supported, use the whole array", op, key)
+	}
+	return nil
+}
+
+func (c *Config) removeOverride(o *override) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, e := range c.overrides {
+		if e == o {
+			c.overrides = append(c.overrides[:i:i], c.overrides[i+1:]...)
+			return
+		}
+	}
+}
+
+// 按设置顺序将覆盖值合并到快照中。
+func (c *Config) mergeOverrides(snap *snapshot) error {
+	c.mu.Lock()
+	list := make([]*override, len(c.overrides))
+	copy(list, c.overrides)
+	c.mu.Unlock()
+
+	for _, o := range list {
+		if err := applyOverride(snap, o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyOverride(snap *snapshot, o *override) error {
+	loc := Location{File: overrideSource}
+	parts := strings.Split(o.key, ".")
+	m := snap.data
+	for i, k := range parts[:len(parts)-1] {
+		switch v := m[k].(type) {
+		case map[string]interface{}:
+			m = v
+		case nil:
+			sub := make(map[string]interface{})
+			m[k] = sub
+			snap.sources[strings.Join(parts[:i+1], ".")] = loc
+			m = sub
+		default:
+			return fmt.Errorf("cfg: override %s: %s is not a table", o.key, strings.Join(parts[:i+1], "."))
+		}
+	}
+
+	k := parts[len(parts)-1]
+	if _, ok := m[k]; ok {
+		forget(snap, o.key)
+	}
+	v := copyValue(o.value)
+	m[k] = v
+	snap.sources[o.key] = loc
+	record(snap, o.key, o.key, v, &Document{}, overrideSource)
+	return nil
+}
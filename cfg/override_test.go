@@ -0,0 +1,78 @@
+package cfg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOverride(t *testing.T) {
+	dir := tempDir(t)
+	file := writeFile(t, dir, "app.toml", "[mongo]\nmaxlink = 10\nservers = \"a\"\n")
+	c, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed := make(chan interface{}, 4)
+	defer c.OnChange("mongo.maxlink", func(old, new interface{}) { changed <- new })()
+
+	restore1, err := c.Override("mongo.maxlink", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restore2, err := c.Override("mongo.maxlink", uint8(30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Override("redis.timeout", 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if v := c.GetInt("mongo.maxlink"); v != 30 {
+		t.Errorf("mongo.maxlink = %d, want 30", v)
+	}
+	if d := c.GetDuration("redis.timeout"); d != 2*time.Second {
+		t.Errorf("redis.timeout = %v", d)
+	}
+	if loc, _ := c.Source("mongo.maxlink"); loc.File != "override" {
+		t.Errorf("Source = %v", loc)
+	}
+
+	// 重新载入后覆盖值依然有效
+	writeFile(t, dir, "app.toml", "[mongo]\nmaxlink = 11\nservers = \"b\"\n")
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if v := c.GetInt("mongo.maxlink"); v != 30 {
+		t.Errorf("mongo.maxlink = %d after reload, want 30", v)
+	}
+
+	if err := restore2(); err != nil {
+		t.Fatal(err)
+	}
+	if v := c.GetInt("mongo.maxlink"); v != 20 {
+		t.Errorf("mongo.maxlink = %d, want 20", v)
+	}
+	if err := restore1(); err != nil {
+		t.Fatal(err)
+	}
+	restore1()
+	if v := c.GetInt("mongo.maxlink"); v != 11 {
+		t.Errorf("mongo.maxlink = %d, want 11", v)
+	}
+	if len(changed) != 4 {
+		t.Errorf("OnChange called %d times, want 4", len(changed))
+	}
+
+	if _, err := c.Override("mongo.servers.host", "x"); err == nil {
+		t.Error("override below a scalar should fail")
+	}
+	if v := c.GetString("mongo.servers"); v != "b" {
+		t.Errorf("failed override should be dropped, mongo.servers = %q", v)
+	}
+	if _, err := c.Override("backends[0].host", "x"); err == nil {
+		t.Error("override of an array element should fail")
+	}
+	if v := c.Get("backends[0]"); v != nil {
+		t.Error("failed override should not create a table")
+	}
+}
@@ -0,0 +1,98 @@
+package cfg
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// 未调用SetProfile时，从该环境变量读取生效的配置方案。
+const ProfileEnv = "APP_PROFILE"
+
+// 配置方案所在的顶层配置键，[profile.prod]定义名为prod的配置方案。
+const profileKey = "profile"
+
+// 选择配置方案，为空串时从ProfileEnv环境变量读取，下次载入时生效。
+//
+// 配置文件中[profile.<name>]下的配置项覆盖同一文件中的基础配置项，如：
+//
+//	[mongo]
+//	servers = "localhost:27017"
+//
+//	[profile.prod.mongo]
+//	servers = "db.prod:27017"
+//
+// 未选择配置方案时只使用基础配置项，profile本身不作为配置项。
+func (c *Config) SetProfile(name string) {
+	c.mu.Lock()
+	c.profile = name
+	c.mu.Unlock()
+}
+
+// 返回生效的配置方案，未选择时返回空串。已载入配置时返回载入时使用的配置方案。
+func (c *Config) ActiveProfile() string {
+	if snap := c.getSnapshot(); snap != nil {
+		return snap.profile
+	}
+	return c.selectedProfile()
+}
+
+func (c *Config) selectedProfile() string {
+	c.mu.Lock()
+	name := c.profile
+	c.mu.Unlock()
+	if name == "" {
+		name = os.Getenv(ProfileEnv)
+	}
+	return name
+}
+
+// 从文件内容中取出配置方案，返回选中的配置方案对应的文档，未定义时返回nil。
+// 文件中定义的配置方案名记入snap.profiles。
+func splitProfile(snap *snapshot, doc *Document, file string) (*Document, error) {
+	v, ok := doc.Values[profileKey]
+	if !ok {
+		return nil, nil
+	}
+	delete(doc.Values, profileKey)
+
+	profiles, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cfg: %s: %v", file, typeMismatch(profileKey, "table", v))
+	}
+	for name, p := range profiles {
+		if !isTable(p) {
+			return nil, fmt.Errorf("cfg: %s: %v", file, typeMismatch(profileKey+"."+name, "table", p))
+		}
+		snap.profiles[name] = true
+	}
+
+	p, ok := profiles[snap.profile]
+	if snap.profile == "" || !ok {
+		return nil, nil
+	}
+
+	// 行号的配置键去掉profile.<name>.前缀，与合并后的配置键一致
+	prefix := profileKey + "." + snap.profile + "."
+	lines := make(map[string]int)
+	for k, line := range doc.Lines {
+		if strings.HasPrefix(k, prefix) {
+			lines[k[len(prefix):]] = line
+		}
+	}
+	return &Document{Values: p.(map[string]interface{}), Lines: lines}, nil
+}
+
+// 检查选中的配置方案是否在配置文件中定义，配置文件未定义任何配置方案时不检查。
+func checkProfile(snap *snapshot) error {
+	if snap.profile == "" || len(snap.profiles) == 0 || snap.profiles[snap.profile] {
+		return nil
+	}
+	names := make([]string, 0, len(snap.profiles))
+	for name := range snap.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("cfg: profile %q not defined, have %s", snap.profile, strings.Join(names, ", "))
+}
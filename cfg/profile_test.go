@@ -0,0 +1,85 @@
+package cfg
+
+import (
+	"os"
+	"testing"
+)
+
+const profileToml = `[mongo]
+servers = "localhost:27017"
+maxlink = 10
+
+[profile.staging.mongo]
+servers = "db.staging:27017"
+
+[profile.prod.mongo]
+servers = "db.prod:27017"
+maxlink = 100
+`
+
+func TestProfile(t *testing.T) {
+	dir := tempDir(t)
+	file := writeFile(t, dir, "app.toml", profileToml)
+
+	c, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := c.ActiveProfile(); p != "" {
+		t.Errorf("ActiveProfile = %q", p)
+	}
+	if v := c.GetString("mongo.servers"); v != "localhost:27017" {
+		t.Errorf("mongo.servers = %q", v)
+	}
+	if _, err := c.GetE("profile"); !IsNotFound(err) {
+		t.Errorf("profile should not be a config key: %v", err)
+	}
+
+	c.SetProfile("prod")
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if p := c.ActiveProfile(); p != "prod" {
+		t.Errorf("ActiveProfile = %q", p)
+	}
+	if v := c.GetString("mongo.servers"); v != "db.prod:27017" {
+		t.Errorf("mongo.servers = %q", v)
+	}
+	if v := c.GetInt("mongo.maxlink"); v != 100 {
+		t.Errorf("mongo.maxlink = %d", v)
+	}
+	if loc, _ := c.Source("mongo.maxlink"); loc.Line != 10 {
+		t.Errorf("Source = %v", loc)
+	}
+
+	c.SetProfile("qa")
+	if err := c.Reload(); err == nil {
+		t.Error("undefined profile should fail")
+	}
+	if p := c.ActiveProfile(); p != "prod" {
+		t.Errorf("failed reload should keep profile, got %q", p)
+	}
+}
+
+func TestProfileEnv(t *testing.T) {
+	dir := tempDir(t)
+	base := writeFile(t, dir, "app.toml", profileToml)
+	local := writeFile(t, dir, "local.toml", "[mongo]\nmaxlink = 5\n")
+
+	os.Setenv(ProfileEnv, "staging")
+	defer os.Unsetenv(ProfileEnv)
+
+	c, err := Load(base, local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := c.ActiveProfile(); p != "staging" {
+		t.Errorf("ActiveProfile = %q", p)
+	}
+	if v := c.GetString("mongo.servers"); v != "db.staging:27017" {
+		t.Errorf("mongo.servers = %q", v)
+	}
+	if v := c.GetInt("mongo.maxlink"); v != 5 {
+		t.Errorf("later files should override profiles of earlier files, mongo.maxlink = %d", v)
+	}
+}
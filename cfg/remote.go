@@ -0,0 +1,333 @@
+package cfg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 远程配置源，如配置中心的HTTP接口。
+type RemoteSource interface {
+	// 配置源名称，用作配置项来源的文件名，如URL。
+	Name() string
+
+	// 获取配置文档。changed为false表示自上次获取以来未变化，此时doc为nil。
+	// 出错时可以同时返回可用的文档，如本地缓存的最后一次成功获取的文档。
+	Fetch(ctx context.Context) (doc *Document, changed bool, err error)
+}
+
+// 远程配置源可以实现Committer，获取的文档通过校验并生效后回调Commit，
+// 如写入本地缓存，作为最后一次可用的配置。
+type Committer interface {
+	Commit(doc *Document)
+}
+
+// 远程配置源及其文档。
+type remote struct {
+	src     RemoteSource
+	doc     *Document // 已生效的文档
+	pending *Document // 新获取的文档，重新载入并通过校验后生效
+}
+
+// 参与一次载入的远程文档。
+type remoteDoc struct {
+	r       *remote
+	doc     *Document
+	pending bool
+}
+
+// 添加远程配置源并立即获取一次。远程配置按添加顺序覆盖在所有配置文件之上。
+// 已载入配置时随即重新载入。获取失败但有可用文档（如本地缓存）时仍然使用该文档，并返回错误。
+// 远程配置的更新由Refresh或Watch拉取，变化时通知OnChange注册的回调。
+// 获取的文档在重新载入并通过校验后才生效，未通过校验的文档被丢弃，继续使用原来的文档。
+func (c *Config) AddRemote(src RemoteSource) error {
+	doc, _, err := src.Fetch(context.Background())
+
+	c.mu.Lock()
+	c.remotes = append(c.remotes, &remote{src: src, pending: doc})
+	c.mu.Unlock()
+
+	if c.getSnapshot() != nil {
+		if rerr := c.Reload(); rerr != nil {
+			return rerr
+		}
+	}
+	return err
+}
+
+// 拉取所有远程配置源，有变化时重新载入配置。
+func (c *Config) Refresh() error {
+	changed, err := c.fetchRemotes(context.Background())
+	if changed {
+		if rerr := c.Reload(); rerr != nil {
+			return rerr
+		}
+	}
+	return err
+}
+
+// 拉取所有远程配置源，新文档等待重新载入后生效，返回是否有变化和第一个错误。
+func (c *Config) fetchRemotes(ctx context.Context) (changed bool, err error) {
+	c.mu.Lock()
+	remotes := make([]*remote, len(c.remotes))
+	copy(remotes, c.remotes)
+	c.mu.Unlock()
+
+	for _, r := range remotes {
+		doc, ok, ferr := r.src.Fetch(ctx)
+		if ferr != nil && err == nil {
+			err = fmt.Errorf("cfg: %s: %v", r.src.Name(), ferr)
+		}
+		if ok && doc != nil {
+			c.mu.Lock()
+			r.pending = doc
+			c.mu.Unlock()
+			changed = true
+		}
+	}
+	return changed, err
+}
+
+// 返回参与载入的远程文档，有新获取的文档时使用新文档，withPending为false时只用已生效的文档。
+// 调用者持有c.mu。
+func (c *Config) remoteDocs(withPending bool) []remoteDoc {
+	var docs []remoteDoc
+	for _, r := range c.remotes {
+		if withPending && r.pending != nil {
+			docs = append(docs, remoteDoc{r: r, doc: r.pending, pending: true})
+		} else if r.doc != nil {
+			docs = append(docs, remoteDoc{r: r, doc: r.doc})
+		}
+	}
+	return docs
+}
+
+// 载入成功后提交新文档。
+func (c *Config) commitRemotes(docs []remoteDoc) {
+	var committed []remoteDoc
+	c.mu.Lock()
+	for _, d := range docs {
+		if d.pending && d.r.pending == d.doc {
+			d.r.doc, d.r.pending = d.doc, nil
+			committed = append(committed, d)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, d := range committed {
+		if cm, ok := d.r.src.(Committer); ok {
+			cm.Commit(d.doc)
+		}
+	}
+}
+
+// 丢弃未通过校验的新文档，此后继续使用原来的文档。
+func (c *Config) rejectRemotes(docs []remoteDoc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, d := range docs {
+		if d.pending && d.r.pending == d.doc {
+			d.r.pending = nil
+		}
+	}
+}
+
+// 将远程配置合并到快照中。合并会修改文档，因此使用副本。
+func mergeRemotes(snap *snapshot, docs []remoteDoc, policy int) error {
+	for _, d := range docs {
+		doc := &Document{Values: copyValue(d.doc.Values).(map[string]interface{}), Lines: d.doc.Lines}
+		if doc.Lines == nil {
+			doc.Lines = make(map[string]int)
+		}
+		if err := mergeDoc(snap, doc, d.r.src.Name(), policy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 通过HTTP轮询的远程配置源，文档为JSON或TOML格式。
+// 使用ETag和If-None-Match避免重复下载未变化的文档。
+type HTTPSource struct {
+	URL string
+
+	// 文档格式，"json"或"toml"。为空时依次按响应的Content-Type、URL扩展名、文档内容判断。
+	Format string
+
+	// 附加的请求头，如认证信息。
+	Header http.Header
+
+	// 为nil时使用超时10秒的默认客户端。
+	Client *http.Client
+
+	// 本地缓存文件，获取的文档通过校验并生效后写入，写入失败的错误由下一次Fetch返回。
+	// 首次获取失败时使用缓存文件中的文档，以便配置中心不可用时仍然可以启动。
+	CacheFile string
+
+	mu       sync.Mutex
+	etag     string
+	fetched  bool      // 已成功获取或使用过缓存
+	last     *Document // 最后一次获取的文档
+	lastBody []byte    // last的原始内容，生效后写入缓存
+	cacheErr error     // 写缓存文件的错误
+}
+
+var defaultHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// 创建HTTP远程配置源，cacheFile为空时不缓存。
+func NewHTTPSource(url, cacheFile string) *HTTPSource {
+	return &HTTPSource{URL: url, CacheFile: cacheFile}
+}
+
+func (s *HTTPSource) Name() string {
+	return s.URL
+}
+
+func (s *HTTPSource) Fetch(ctx context.Context) (*Document, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	doc, changed, err := s.fetch(ctx)
+	if err == nil && s.cacheErr != nil {
+		err, s.cacheErr = s.cacheErr, nil
+	}
+	if err != nil && !s.fetched && s.CacheFile != "" {
+		if cached, cerr := s.readCache(); cerr == nil {
+			s.fetched = true
+			return cached, true, err
+		}
+	}
+	if err == nil {
+		s.fetched = true
+	}
+	return doc, changed, err
+}
+
+func (s *HTTPSource) fetch(ctx context.Context) (*Document, bool, error) {
+	req, err := http.NewRequest("GET", s.URL, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req = req.WithContext(ctx)
+	for k, vs := range s.Header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	if s.etag != "" {
+		req.Header.Set("If-None-Match", s.etag)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+
+	doc, err := s.loader(resp.Header.Get("Content-Type"), body).Load(body)
+	if err != nil {
+		return nil, false, err
+	}
+	s.etag = resp.Header.Get("ETag")
+	s.last, s.lastBody = doc, body
+	return doc, true, nil
+}
+
+// 文档生效后写入缓存文件。
+func (s *HTTPSource) Commit(doc *Document) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if doc != s.last || s.CacheFile == "" {
+		return
+	}
+	if err := writeFileAtomic(s.CacheFile, s.lastBody, 0600); err != nil {
+		s.cacheErr = fmt.Errorf("cache: %v", err)
+	}
+	s.lastBody = nil
+}
+
+func (s *HTTPSource) readCache() (*Document, error) {
+	body, err := ioutil.ReadFile(s.CacheFile)
+	if err != nil {
+		return nil, err
+	}
+	return s.loader("", body).Load(body)
+}
+
+// 选择文档的解析器。
+func (s *HTTPSource) loader(contentType string, body []byte) Loader {
+	format := strings.ToLower(s.Format)
+	if format == "" {
+		switch {
+		case strings.Contains(contentType, "json"):
+			format = "json"
+		case strings.Contains(contentType, "toml"):
+			format = "toml"
+		}
+	}
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(strings.SplitN(s.URL, "?", 2)[0])) {
+		case ".json":
+			format = "json"
+		case ".toml":
+			format = "toml"
+		}
+	}
+	if format == "" && bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+		format = "json"
+	}
+	if format == "json" {
+		return JSONLoader{}
+	}
+	return TOMLLoader{}
+}
+
+// 先写入临时文件再改名，避免读到写了一半的文件。
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
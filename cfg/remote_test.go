@@ -0,0 +1,170 @@
+package cfg
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 模拟配置中心，支持ETag。
+type configServer struct {
+	mu          sync.Mutex
+	body        string
+	etag        string
+	notModified int
+}
+
+func (s *configServer) set(body, etag string) {
+	s.mu.Lock()
+	s.body, s.etag = body, etag
+	s.mu.Unlock()
+}
+
+func (s *configServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Header.Get("If-None-Match") == s.etag {
+		s.notModified++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", s.etag)
+	w.Header().Set("Content-Type", "application/toml")
+	w.Write([]byte(s.body))
+}
+
+func TestHTTPSource(t *testing.T) {
+	cs := &configServer{}
+	cs.set("[mongo]\nmaxlink = 50\n", `"v1"`)
+	srv := httptest.NewServer(cs)
+	defer srv.Close()
+
+	dir := tempDir(t)
+	file := writeFile(t, dir, "app.toml", "[mongo]\nmaxlink = 10\nservers = \"a\"\n")
+	cache := filepath.Join(dir, "remote.cache")
+
+	c, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddRemote(NewHTTPSource(srv.URL, cache)); err != nil {
+		t.Fatal(err)
+	}
+	if v := c.GetInt("mongo.maxlink"); v != 50 {
+		t.Errorf("remote should override file, mongo.maxlink = %d", v)
+	}
+	if v := c.GetString("mongo.servers"); v != "a" {
+		t.Errorf("mongo.servers = %q", v)
+	}
+	if loc, _ := c.Source("mongo.maxlink"); loc.File != srv.URL {
+		t.Errorf("Source = %v", loc)
+	}
+
+	changed := make(chan interface{}, 1)
+	defer c.OnChange("mongo.maxlink", func(old, new interface{}) { changed <- new })()
+
+	if err := c.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	cs.mu.Lock()
+	if cs.notModified != 1 {
+		t.Errorf("second fetch should send If-None-Match, notModified = %d", cs.notModified)
+	}
+	cs.mu.Unlock()
+
+	cs.set("[mongo]\nmaxlink = 60\n", `"v2"`)
+	stop := c.Watch(10*time.Millisecond, nil)
+	defer stop()
+	select {
+	case v := <-changed:
+		if v != int64(60) {
+			t.Errorf("OnChange new = %v", v)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("watch did not pick up remote change")
+	}
+}
+
+func TestHTTPSourceCache(t *testing.T) {
+	cs := &configServer{}
+	cs.set(`{"mongo": {"maxlink": 70}}`, `"v1"`)
+	srv := httptest.NewServer(cs)
+
+	dir := tempDir(t)
+	file := writeFile(t, dir, "app.toml", "[mongo]\nmaxlink = 10\n")
+	cache := filepath.Join(dir, "remote.cache")
+
+	c, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := &HTTPSource{URL: srv.URL, Format: "json", CacheFile: cache}
+	if err := c.AddRemote(src); err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+
+	if err := c.Refresh(); err == nil {
+		t.Error("Refresh should report the unreachable server")
+	}
+	if v := c.GetInt("mongo.maxlink"); v != 70 {
+		t.Errorf("failed fetch should keep last value, mongo.maxlink = %d", v)
+	}
+
+	c2, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c2.AddRemote(&HTTPSource{URL: srv.URL, Format: "json", CacheFile: cache}); err == nil {
+		t.Error("AddRemote should report the unreachable server")
+	}
+	if v := c2.GetInt("mongo.maxlink"); v != 70 {
+		t.Errorf("offline start should use the cache, mongo.maxlink = %d", v)
+	}
+}
+
+func TestRemoteRejected(t *testing.T) {
+	cs := &configServer{}
+	cs.set("[mongo]\nmaxlink = 50\n", `"v1"`)
+	srv := httptest.NewServer(cs)
+	defer srv.Close()
+
+	dir := tempDir(t)
+	file := writeFile(t, dir, "app.toml", "[mongo]\nmaxlink = 10\nservers = \"a\"\n")
+	cache := filepath.Join(dir, "remote.cache")
+
+	c, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RegisterJSONSchema([]byte(`{"properties": {"mongo": {"properties": {"maxlink": {"maximum": 100}}}}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddRemote(NewHTTPSource(srv.URL, cache)); err != nil {
+		t.Fatal(err)
+	}
+
+	cs.set("[mongo]\nmaxlink = 500\n", `"v2"`)
+	if err := c.Refresh(); err == nil {
+		t.Error("Refresh should reject the invalid remote document")
+	}
+	if v := c.GetInt("mongo.maxlink"); v != 50 {
+		t.Errorf("mongo.maxlink = %d", v)
+	}
+	if b, err := ioutil.ReadFile(cache); err != nil || !strings.Contains(string(b), "50") || strings.Contains(string(b), "500") {
+		t.Errorf("cache = %q, %v", b, err)
+	}
+
+	writeFile(t, dir, "app.toml", "[mongo]\nmaxlink = 10\nservers = \"b\"\n")
+	if err := c.Reload(); err != nil {
+		t.Fatalf("reload after rejected remote document = %v", err)
+	}
+	if v, n := c.GetString("mongo.servers"), c.GetInt("mongo.maxlink"); v != "b" || n != 50 {
+		t.Errorf("mongo.servers = %q, mongo.maxlink = %d", v, n)
+	}
+}
@@ -0,0 +1,576 @@
+package cfg
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// 由Set设置、等待Save写入的配置项。
+type edit struct {
+	key     string
+	value   interface{}
+	encrypt bool // 原值为加密值，保存时加密
+	saved   bool // 已由Save写入，设置的值仍然生效
+	o       *override
+}
+
+// 设置配置项key的值并立即生效，之后可以用Save写入配置文件。
+//
+// key已存在时value须与原值类型一致，整数可以赋给浮点数；表不能整体设置，需要逐项设置；
+// key不能包含数组下标，需要设置整个数组。
+// value的类型规则同Override，设置的值同样位于配置文件之上，重新载入后依然有效。
+// 原值为加密值时，该配置项标记为敏感配置项，Save写入加密后的值。
+// 设置后载入失败，如未通过RegisterSchema注册的校验时，返回错误，原配置保持不变。
+func (c *Config) Set(key string, value interface{}) error {
+	if key == "" {
+		return fmt.Errorf("cfg: set requires a key")
+	}
+	if err := checkTableKey("set", key); err != nil {
+		return err
+	}
+	v, err := templateValue(reflect.ValueOf(&value).Elem())
+	if err != nil {
+		return fmt.Errorf("cfg: set %s: %v", key, err)
+	}
+	if isTable(v) {
+		return fmt.Errorf("cfg: set %s: cannot set a table, set its keys instead", key)
+	}
+
+	e := &edit{key: key}
+	if snap := c.getSnapshot(); snap != nil {
+		if old, ok := lookup(snap.data, key); ok {
+			if v, err = checkSet(key, old, v); err != nil {
+				return err
+			}
+		}
+		e.encrypt = snap.encrypted[key]
+	}
+	e.value = v
+	e.o = &override{key: key, value: v}
+
+	c.mu.Lock()
+	var prev *edit
+	for _, old := range c.edits {
+		if old.key == key {
+			prev = old
+			e.encrypt = e.encrypt || old.encrypt
+		}
+	}
+	c.replaceEdit(prev, e)
+	c.mu.Unlock()
+
+	if err := c.Reload(); err != nil {
+		c.mu.Lock()
+		c.replaceEdit(e, prev)
+		c.mu.Unlock()
+		return err
+	}
+	if e.encrypt {
+		c.MarkSecret(key)
+	}
+	return nil
+}
+
+// 用new替换old及其覆盖值，位置不变；old为nil时添加new，new为nil时删除old。调用者持有c.mu。
+// 总是生成新的切片，mergeOverrides可能正在使用旧切片的副本。
+func (c *Config) replaceEdit(old, new *edit) {
+	edits := make([]*edit, 0, len(c.edits)+1)
+	overrides := make([]*override, 0, len(c.overrides)+1)
+	for _, e := range c.edits {
+		if e != old {
+			edits = append(edits, e)
+		} else if new != nil {
+			edits = append(edits, new)
+		}
+	}
+	for _, o := range c.overrides {
+		if old == nil || o != old.o {
+			overrides = append(overrides, o)
+		} else if new != nil {
+			overrides = append(overrides, new.o)
+		}
+	}
+	if old == nil && new != nil {
+		edits = append(edits, new)
+		overrides = append(overrides, new.o)
+	}
+	c.edits, c.overrides = edits, overrides
+}
+
+// 检查新值与原值的类型是否一致，返回转换后的新值。
+func checkSet(key string, old, v interface{}) (interface{}, error) {
+	if _, ok := old.(float64); ok {
+		if n, ok := v.(int64); ok {
+			return float64(n), nil
+		}
+	}
+	if typeName(old) != typeName(v) {
+		return nil, typeMismatch(key, typeName(old), v)
+	}
+	return v, nil
+}
+
+// 将Set设置的配置项写入TOML格式的配置文件path。
+//
+// path已存在时只修改被设置的配置项的值，文件中的注释、配置项顺序和其他内容保持不变；
+// 文件中没有的配置项添加到所在表的末尾，表不存在时在文件末尾新建。
+// 只写入上次成功保存之后设置的配置项，已保存的配置项不再写入其他文件。
+// path不存在时新建文件，写入当前生效的全部配置数据，即合并后的配置文件、远程配置和覆盖值，
+// 引用已解析，不含环境变量和命令行参数；加密的值重新加密后写入。
+// 内容先写入同目录下的临时文件再改名替换原文件，写入失败时原文件保持不变。
+func (c *Config) Save(path string) error {
+	c.mu.Lock()
+	var edits []*edit
+	for _, e := range c.edits {
+		if !e.saved {
+			edits = append(edits, e)
+		}
+	}
+	c.mu.Unlock()
+
+	perm := os.FileMode(0644)
+	src, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c.saveAll(path, edits)
+	}
+	if err != nil {
+		return err
+	}
+	if fi, err := os.Stat(path); err == nil {
+		perm = fi.Mode().Perm()
+	}
+
+	nl := "\n"
+	if strings.Contains(string(src), "\r\n") {
+		nl = "\r\n"
+	}
+	text := string(src)
+	var key []byte
+	for _, e := range edits {
+		v := e.value
+		if s, ok := v.(string); ok && e.encrypt {
+			if key == nil {
+				if key, err = c.getSecretKey(); err != nil {
+					return fmt.Errorf("cfg: save %s: %v", e.key, err)
+				}
+			}
+			if v, err = Encrypt(key, s); err != nil {
+				return fmt.Errorf("cfg: save %s: %v", e.key, err)
+			}
+		}
+		if text, err = applyEdit(text, e.key, v, nl); err != nil {
+			return fmt.Errorf("cfg: save %s: %s: %v", path, e.key, err)
+		}
+	}
+	if err := writeFileAtomic(path, []byte(text), perm); err != nil {
+		return err
+	}
+	c.markSaved(edits)
+	return nil
+}
+
+// 将当前生效的全部配置数据写入新文件path，见Save。
+func (c *Config) saveAll(path string, edits []*edit) error {
+	snap := c.getSnapshot()
+	if snap == nil {
+		return ErrNotLoaded
+	}
+	encrypt := make(map[string]bool, len(snap.encrypted))
+	for k := range snap.encrypted {
+		encrypt[k] = true
+	}
+	c.mu.Lock()
+	for _, e := range c.edits {
+		encrypt[e.key] = encrypt[e.key] || e.encrypt
+	}
+	c.mu.Unlock()
+
+	var key []byte
+	var walk func(p string, v interface{}) (interface{}, error)
+	walk = func(p string, v interface{}) (interface{}, error) {
+		switch val := v.(type) {
+		case map[string]interface{}:
+			for k, sub := range val {
+				nv, err := walk(joinKey(p, k), sub)
+				if err != nil {
+					return nil, err
+				}
+				val[k] = nv
+			}
+		case []interface{}:
+			for i, sub := range val {
+				nv, err := walk(fmt.Sprintf("%s[%d]", p, i), sub)
+				if err != nil {
+					return nil, err
+				}
+				val[i] = nv
+			}
+		case string:
+			if !encrypt[p] {
+				break
+			}
+			if key == nil {
+				k, err := c.getSecretKey()
+				if err != nil {
+					return nil, fmt.Errorf("cfg: save %s: %v", p, err)
+				}
+				key = k
+			}
+			s, err := Encrypt(key, val)
+			if err != nil {
+				return nil, fmt.Errorf("cfg: save %s: %v", p, err)
+			}
+			return s, nil
+		}
+		return v, nil
+	}
+	data, err := walk("", copyValue(snap.data))
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeTOML(&buf, data.(map[string]interface{})); err != nil {
+		return fmt.Errorf("cfg: save %s: %v", path, err)
+	}
+	if err := writeFileAtomic(path, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	c.markSaved(edits)
+	return nil
+}
+
+func (c *Config) markSaved(edits []*edit) {
+	c.mu.Lock()
+	for _, e := range edits {
+		e.saved = true
+	}
+	c.mu.Unlock()
+}
+
+// 在TOML文本中设置配置项key的值，并检查结果能否正确解析。
+func applyEdit(text, key string, v interface{}, nl string) (string, error) {
+	val, err := tomlValue(v)
+	if err != nil {
+		return "", err
+	}
+	doc, err := TOMLLoader{}.Load([]byte(text))
+	if err != nil {
+		return "", err
+	}
+
+	i := strings.LastIndex(key, ".")
+	parent, name := "", key
+	if i >= 0 {
+		parent, name = key[:i], key[i+1:]
+	}
+	if _, ok := lookup(doc.Values, key); ok {
+		text, err = replaceValue(text, doc.Lines[key], name, val)
+	} else {
+		text, err = insertKey(text, doc, parent, name, val, nl)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	doc, err = TOMLLoader{}.Load([]byte(text))
+	if err != nil {
+		return "", fmt.Errorf("edited file does not parse: %v", err)
+	}
+	if got, _ := lookup(doc.Values, key); !sameValue(got, v) {
+		return "", fmt.Errorf("edited file has %v, want %v", got, v)
+	}
+	return text, nil
+}
+
+// 替换第line行上配置项name的值，保留行尾注释。
+func replaceValue(text string, line int, name, val string) (string, error) {
+	start := lineOffset(text, line)
+	if line <= 0 || start < 0 {
+		return "", fmt.Errorf("cannot locate the value")
+	}
+	end := strings.IndexByte(text[start:], '\n')
+	if end < 0 {
+		end = len(text) - start
+	}
+	k, off, ok := splitAssign(text[start : start+end])
+	if !ok || k != name {
+		return "", fmt.Errorf("line %d is not a plain assignment", line)
+	}
+	from := start + off
+	to := scanValue(text, from)
+	return text[:from] + val + text[to:], nil
+}
+
+// 在表parent中添加配置项，parent为空串表示顶层。
+func insertKey(text string, doc *Document, parent, name, val, nl string) (string, error) {
+	entry := tomlKey(name) + " = " + val + nl
+	table := doc.Values
+	if parent != "" {
+		v, ok := lookup(doc.Values, parent)
+		if !ok {
+			return appendSection(text, parent, entry, nl), nil
+		}
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("%s is not a table", parent)
+		}
+		line := doc.Lines[parent]
+		if !isHeader(lineText(text, line), parent) {
+			if strings.HasPrefix(strings.TrimSpace(lineText(text, line)), "[") {
+				// 表由下级表隐式定义，可以在文件末尾补充定义
+				return appendSection(text, parent, entry, nl), nil
+			}
+			return "", fmt.Errorf("%s is an inline table", parent)
+		}
+		table = m
+		if last := lastValueLine(doc, parent, table); last == 0 {
+			return insertAt(text, line+1, entry), nil
+		}
+	}
+
+	if last := lastValueLine(doc, parent, table); last > 0 {
+		end := scanValue(text, lineOffset(text, last)+valueOffset(text, last))
+		if i := strings.IndexByte(text[end:], '\n'); i >= 0 {
+			end += i + 1
+		} else {
+			end = len(text)
+			entry = nl + entry
+		}
+		return text[:end] + entry + text[end:], nil
+	}
+
+	// 顶层没有配置项时，添加在第一个表之前
+	first := 0
+	for k, v := range table {
+		if l := doc.Lines[k]; l > 0 && (isTable(v) || isTableArray(v)) && (first == 0 || l < first) {
+			first = l
+		}
+	}
+	if first == 0 {
+		return appendLine(text, entry, nl), nil
+	}
+	return insertAt(text, first, entry+nl), nil
+}
+
+// 返回表中最后一个非表配置项所在的行，没有时返回0。
+func lastValueLine(doc *Document, path string, table map[string]interface{}) int {
+	last := 0
+	for k, v := range table {
+		if isTable(v) || isTableArray(v) {
+			continue
+		}
+		if l := doc.Lines[joinKey(path, k)]; l > last {
+			last = l
+		}
+	}
+	return last
+}
+
+func appendSection(text, table, entry, nl string) string {
+	if strings.TrimSpace(text) != "" {
+		text = appendLine(text, "", nl) + nl
+	}
+	parts := strings.Split(table, ".")
+	for i, p := range parts {
+		parts[i] = tomlKey(p)
+	}
+	return text + "[" + strings.Join(parts, ".") + "]" + nl + entry
+}
+
+// 在文本末尾添加一行，原文本不以换行结尾时先补充换行。
+func appendLine(text, line, nl string) string {
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += nl
+	}
+	return text + line
+}
+
+// 在第line行之前插入s，line超出文本时添加在末尾。
+func insertAt(text string, line int, s string) string {
+	i := lineOffset(text, line)
+	if i < 0 {
+		return appendLine(text, s, "\n")
+	}
+	return text[:i] + s + text[i:]
+}
+
+// 返回第line行（从1开始）的起始位置，不存在时返回-1。
+func lineOffset(text string, line int) int {
+	off := 0
+	for n := 1; n < line; n++ {
+		i := strings.IndexByte(text[off:], '\n')
+		if i < 0 {
+			return -1
+		}
+		off += i + 1
+	}
+	if off >= len(text) && line > 1 {
+		return -1
+	}
+	return off
+}
+
+func lineText(text string, line int) string {
+	i := lineOffset(text, line)
+	if i < 0 {
+		return ""
+	}
+	if j := strings.IndexByte(text[i:], '\n'); j >= 0 {
+		return text[i : i+j]
+	}
+	return text[i:]
+}
+
+// 返回第line行中值的起始位置，相对于行首。
+func valueOffset(text string, line int) int {
+	_, off, _ := splitAssign(lineText(text, line))
+	return off
+}
+
+// 判断一行是否为表头[table]。
+func isHeader(line, table string) bool {
+	s := strings.TrimSpace(line)
+	if strings.HasPrefix(s, "[[") || !strings.HasPrefix(s, "[") {
+		return false
+	}
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return false
+	}
+	parts := strings.Split(s[1:end], ".")
+	for i, p := range parts {
+		parts[i] = strings.Trim(strings.TrimSpace(p), `"'`)
+	}
+	return strings.Join(parts, ".") == table
+}
+
+// 拆分一行中的"key = value"，返回去掉引号的键名和值在行中的起始位置。
+func splitAssign(line string) (key string, off int, ok bool) {
+	eq := -1
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' && quote == '"' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '=':
+			eq = i
+		}
+		if eq >= 0 {
+			break
+		}
+	}
+	if eq < 0 {
+		return "", 0, false
+	}
+	key = strings.TrimSpace(line[:eq])
+	if len(key) >= 2 && (key[0] == '"' || key[0] == '\'') && key[len(key)-1] == key[0] {
+		key = key[1 : len(key)-1]
+	}
+	off = eq + 1
+	for off < len(line) && (line[off] == ' ' || line[off] == '\t') {
+		off++
+	}
+	return key, off, true
+}
+
+// 返回从i开始的TOML值的结束位置，值可以是多行字符串或跨行的数组、内联表。
+func scanValue(s string, i int) int {
+	switch {
+	case i >= len(s):
+		return i
+	case strings.HasPrefix(s[i:], `"""`), strings.HasPrefix(s[i:], `'''`):
+		delim := s[i : i+3]
+		j := i + 3
+		for j < len(s) {
+			if delim == `"""` && s[j] == '\\' {
+				j += 2
+				continue
+			}
+			if strings.HasPrefix(s[j:], delim) {
+				j += 3
+				for j < len(s) && s[j] == delim[0] {
+					j++
+				}
+				return j
+			}
+			j++
+		}
+		return len(s)
+	case s[i] == '"' || s[i] == '\'':
+		j := i + 1
+		for j < len(s) && s[j] != s[i] && s[j] != '\n' {
+			if s[i] == '"' && s[j] == '\\' {
+				j++
+			}
+			j++
+		}
+		if j < len(s) && s[j] == s[i] {
+			j++
+		}
+		return j
+	case s[i] == '[' || s[i] == '{':
+		depth := 0
+		for j := i; j < len(s); {
+			switch s[j] {
+			case '[', '{':
+				depth++
+			case ']', '}':
+				depth--
+				if depth == 0 {
+					return j + 1
+				}
+			case '"', '\'':
+				j = scanValue(s, j)
+				continue
+			case '#':
+				for j < len(s) && s[j] != '\n' {
+					j++
+				}
+				continue
+			}
+			j++
+		}
+		return len(s)
+	}
+
+	// 数字、布尔值和日期时间，日期时间中可以有空格
+	j := i
+	for j < len(s) && s[j] != '\n' && s[j] != '#' {
+		j++
+	}
+	return i + len(strings.TrimRight(s[i:j], " \t\r"))
+}
+
+// 比较写入文件后解析出的值与设置的值。
+func sameValue(a, b interface{}) bool {
+	if ta, ok := a.(time.Time); ok {
+		tb, ok := b.(time.Time)
+		return ok && ta.Equal(tb)
+	}
+	if aa, ok := a.([]interface{}); ok {
+		bb, ok := b.([]interface{})
+		if !ok || len(aa) != len(bb) {
+			return false
+		}
+		for i := range aa {
+			if !sameValue(aa[i], bb[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
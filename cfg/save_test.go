@@ -0,0 +1,196 @@
+package cfg
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const saveToml = `# 服务配置
+name = "app" # 服务名
+ratio = 0.5
+
+# 数据库
+[mongo]
+servers = "localhost:27017"
+hosts = [
+	"a", # 主
+	"b",
+]
+maxlink = 10   # 最大连接数
+
+[cache]
+`
+
+func TestSetSave(t *testing.T) {
+	dir := tempDir(t)
+	file := writeFile(t, dir, "app.toml", saveToml)
+	if err := os.Chmod(file, 0600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var te *ErrTypeMismatch
+	if err := c.Set("mongo.maxlink", "20"); !errors.As(err, &te) {
+		t.Errorf("Set string over integer = %v", err)
+	}
+	if err := c.Set("mongo", 1); !errors.As(err, &te) {
+		t.Errorf("Set over table = %v", err)
+	}
+
+	sets := []struct {
+		key   string
+		value interface{}
+	}{
+		{"mongo.maxlink", 20},
+		{"mongo.maxlink", 30},
+		{"ratio", 1},
+		{"mongo.hosts", []string{"c"}},
+		{"mongo.timeout", 5 * time.Second},
+		{"debug", true},
+		{"cache.size", 100},
+		{"redis.addr", "127.0.0.1:6379"},
+	}
+	for _, s := range sets {
+		if err := c.Set(s.key, s.value); err != nil {
+			t.Fatalf("Set(%s) = %v", s.key, err)
+		}
+	}
+	if v := c.GetInt("mongo.maxlink"); v != 30 {
+		t.Errorf("mongo.maxlink = %d", v)
+	}
+	if v := c.GetFloat64("ratio"); v != 1 {
+		t.Errorf("ratio = %v", v)
+	}
+
+	if err := c.Save(file); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# 服务配置
+name = "app" # 服务名
+ratio = 1.0
+debug = true
+
+# 数据库
+[mongo]
+servers = "localhost:27017"
+hosts = ["c"]
+maxlink = 30   # 最大连接数
+timeout = "5s"
+
+[cache]
+size = 100
+
+[redis]
+addr = "127.0.0.1:6379"
+`
+	if string(b) != want {
+		t.Errorf("saved file =\n%s\nwant\n%s", b, want)
+	}
+	if fi, err := os.Stat(file); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("file mode not kept: %v %v", fi.Mode(), err)
+	}
+
+	// 已有文件只写入上次保存后设置的配置项
+	if err := c.Set("redis.addr", "10.0.0.1:6379"); err != nil {
+		t.Fatal(err)
+	}
+	other := writeFile(t, dir, "other.toml", "# 本地配置\n")
+	if err := c.Save(other); err != nil {
+		t.Fatal(err)
+	}
+	c2, err := Load(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := c2.GetString("redis.addr"); v != "10.0.0.1:6379" {
+		t.Errorf("redis.addr = %q", v)
+	}
+	if v := c2.Get("mongo.timeout"); v != nil {
+		t.Errorf("saved edits written again, mongo.timeout = %v", v)
+	}
+	if v := c.GetDuration("mongo.timeout"); v != 5*time.Second {
+		t.Errorf("saved edits should stay in effect, mongo.timeout = %v", v)
+	}
+
+	// 新建文件时写入全部配置
+	if err := c.Set("mongo.maxlink", 40); err != nil {
+		t.Fatal(err)
+	}
+	created := dir + "/new.toml"
+	if err := c.Save(created); err != nil {
+		t.Fatal(err)
+	}
+	c3, err := Load(created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"name", "mongo.servers", "mongo.timeout", "mongo.maxlink", "redis.addr"} {
+		if got, want := c3.Get(k), c.Get(k); !reflect.DeepEqual(got, want) {
+			t.Errorf("new file %s = %v, want %v", k, got, want)
+		}
+	}
+
+	if err := c.Set("backends[0].host", "x"); err == nil {
+		t.Error("Set of an array element should fail")
+	}
+}
+
+func TestSaveEncrypted(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := Encrypt(key, "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := tempDir(t)
+	file := writeFile(t, dir, "app.toml", "[db]\npassword = \""+enc+"\"\n")
+
+	c := New()
+	c.SetSecretKey(key)
+	if err := c.LoadConfig(file); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set("db.password", "new"); err != nil {
+		t.Fatal(err)
+	}
+	if !c.IsSecret("db.password") {
+		t.Error("replaced secret should stay secret")
+	}
+	if err := c.Save(file); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(file)
+	if strings.Contains(string(b), "new") || !strings.Contains(string(b), EncPrefix) {
+		t.Errorf("secret saved in plain text:\n%s", b)
+	}
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if v := c.GetString("db.password"); v != "new" {
+		t.Errorf("db.password = %q", v)
+	}
+
+	// 新建的文件中同样为加密值
+	created := dir + "/new.toml"
+	if err := c.Save(created); err != nil {
+		t.Fatal(err)
+	}
+	b, _ = ioutil.ReadFile(created)
+	if strings.Contains(string(b), "new") || !strings.Contains(string(b), EncPrefix) {
+		t.Errorf("secret saved in plain text:\n%s", b)
+	}
+}
@@ -0,0 +1,198 @@
+package cfg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// 敏感配置项输出时的替代值。
+const Redacted = "******"
+
+// 键名中含有这些单词的配置项视为敏感配置项。
+var secretWords = []string{"password", "passwd", "secret", "token", "credential", "credentials"}
+
+// RedactText替换的敏感值的最小长度，过短的值容易误伤普通文本。
+const minRedactLen = 4
+
+// 将配置键标记为敏感配置项，其下级配置键同样视为敏感配置项。
+// 敏感配置项在Redacted、RedactChanges等输出中隐藏。
+func (c *Config) MarkSecret(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.secrets == nil {
+		c.secrets = make(map[string]bool)
+	}
+	for _, k := range keys {
+		c.secrets[k] = true
+	}
+}
+
+// 判断配置项是否为敏感配置项，输出时应隐藏。以下配置项视为敏感配置项：
+// 用MarkSecret标记的配置项及其下级配置项；值经过解密的配置项；
+// 值中引用了敏感配置项的配置项，如url = "mongodb://u:${db.password}@h"；
+// 最后一级键名含有password、secret、token等单词，或以key为最后一个单词的配置项，
+// 如db_password、accessToken、api-key、apikey；max_tokens、keys等不视为敏感配置项。
+func (c *Config) IsSecret(key string) bool {
+	return c.isSecret(c.getSnapshot(), key)
+}
+
+func (c *Config) isSecret(snap *snapshot, key string) bool {
+	if snap != nil && (snap.encrypted[key] || snap.secrets[key]) {
+		return true
+	}
+	c.mu.Lock()
+	for k := range c.secrets {
+		if key == k || strings.HasPrefix(key, k+".") || strings.HasPrefix(key, k+"[") {
+			c.mu.Unlock()
+			return true
+		}
+	}
+	c.mu.Unlock()
+
+	name := key
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.Index(name, "["); i >= 0 {
+		name = name[:i]
+	}
+	if strings.ToLower(name) == "apikey" {
+		return true
+	}
+
+	words := keyWords(name)
+	if len(words) > 0 && words[len(words)-1] == "key" {
+		return true
+	}
+	for _, word := range words {
+		for _, w := range secretWords {
+			if word == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 将键名拆分为小写的单词，以"_"、"-"和大小写变化分隔，
+// 如db_password、dbPassword、DBPassword均拆分为db、password。
+func keyWords(name string) []string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = word[:0]
+		}
+	}
+
+	rs := []rune(name)
+	for i, r := range rs {
+		if r == '_' || r == '-' {
+			flush()
+			continue
+		}
+		if unicode.IsUpper(r) && i > 0 {
+			prev := rs[i-1]
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || i+1 < len(rs) && unicode.IsLower(rs[i+1]) {
+				flush()
+			}
+		}
+		word = append(word, unicode.ToLower(r))
+	}
+	flush()
+	return words
+}
+
+// 返回隐藏了敏感配置项的副本，path为v对应的配置键。
+func (c *Config) Redact(path string, v interface{}) interface{} {
+	if path != "" && c.IsSecret(path) {
+		return Redacted
+	}
+	switch val := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, sub := range val {
+			m[k] = c.Redact(joinKey(path, k), sub)
+		}
+		return m
+	case []interface{}:
+		arr := make([]interface{}, len(val))
+		for i, sub := range val {
+			if isTable(sub) {
+				arr[i] = c.Redact(fmt.Sprintf("%s[%d]", path, i), sub)
+			} else {
+				arr[i] = copyValue(sub)
+			}
+		}
+		return arr
+	}
+	return copyValue(v)
+}
+
+// 返回生效的全部配置数据，已应用命令行参数和环境变量覆盖，并隐藏敏感配置项。
+func (c *Config) Redacted() (map[string]interface{}, error) {
+	v, ok := c.getValue("")
+	if !ok {
+		return nil, ErrNotLoaded
+	}
+	return c.Redact("", c.overlay("", v)).(map[string]interface{}), nil
+}
+
+// 隐藏变化中的敏感配置项。
+func (c *Config) RedactChanges(changes []Change) []Change {
+	ret := make([]Change, len(changes))
+	for i, ch := range changes {
+		ch.Old, ch.New = c.Redact(ch.Key, ch.Old), c.Redact(ch.Key, ch.New)
+		if ch.Kind == ChangeAdded {
+			ch.Old = nil
+		}
+		if ch.Kind == ChangeRemoved {
+			ch.New = nil
+		}
+		ret[i] = ch
+	}
+	return ret
+}
+
+// 将文本中出现的敏感配置项的值替换为Redacted，用于输出日志等场合。
+// 只替换长度不小于4的字符串值，数字、布尔值等不替换。
+func (c *Config) RedactText(s string) string {
+	v, ok := c.getValue("")
+	if !ok {
+		return s
+	}
+
+	var values []string
+	var walk func(path string, v interface{})
+	walk = func(path string, v interface{}) {
+		switch val := v.(type) {
+		case map[string]interface{}:
+			for k, sub := range val {
+				walk(joinKey(path, k), sub)
+			}
+		case []interface{}:
+			for i, sub := range val {
+				if isTable(sub) {
+					walk(fmt.Sprintf("%s[%d]", path, i), sub)
+				} else if str, ok := sub.(string); ok && len(str) >= minRedactLen && c.IsSecret(path) {
+					values = append(values, str)
+				}
+			}
+		case string:
+			if len(val) >= minRedactLen && c.IsSecret(path) {
+				values = append(values, val)
+			}
+		}
+	}
+	walk("", c.overlay("", v))
+
+	// 先替换较长的值，避免其中包含的较短值被部分替换
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	for _, secret := range values {
+		s = strings.Replace(s, secret, Redacted, -1)
+	}
+	return s
+}
@@ -0,0 +1,125 @@
+package cfg
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// 读取时指定了默认值且配置项不存在。
+var errUseDef = errors.New("cfg: use default")
+
+// 配置项声明。
+type declaration struct {
+	def    interface{}
+	hasDef bool
+}
+
+// 声明配置项，def为可选的默认值。配置项不存在且读取时未指定默认值时，读取接口返回声明的默认值。
+// 严格模式下，载入配置时检查所有未提供默认值的声明配置项是否存在。
+func (c *Config) Declare(key string, def ...interface{}) {
+	d := declaration{}
+	if len(def) > 0 {
+		d.def, d.hasDef = normalizeDefault(def[0]), true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.declared == nil {
+		c.declared = make(map[string]declaration)
+	}
+	c.declared[key] = d
+}
+
+// 设置严格模式，下次载入时生效。
+func (c *Config) SetStrict(strict bool) {
+	c.mu.Lock()
+	c.strict = strict
+	c.mu.Unlock()
+}
+
+// 返回声明的默认值。
+func (c *Config) declaredDefault(key string) (interface{}, bool) {
+	c.mu.Lock()
+	d, ok := c.declared[key]
+	c.mu.Unlock()
+	if !ok || !d.hasDef {
+		return nil, false
+	}
+	return copyValue(d.def), true
+}
+
+// 检查未提供默认值的声明配置项是否都存在，缺少时返回*ErrMissingKeys。
+func (c *Config) Check() error {
+	snap := c.getSnapshot()
+	if snap == nil {
+		return ErrNotLoaded
+	}
+	return c.checkRequired(snap)
+}
+
+func (c *Config) checkRequired(snap *snapshot) error {
+	c.mu.Lock()
+	var missing []string
+	for key, d := range c.declared {
+		if d.hasDef {
+			continue
+		}
+		if _, ok := lookup(snap.data, key); ok {
+			continue
+		}
+		if _, ok := c.lookupOverride(key); ok {
+			continue
+		}
+		missing = append(missing, key)
+	}
+	c.mu.Unlock()
+
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return &ErrMissingKeys{Keys: missing}
+}
+
+// 将默认值转换为与配置文件中相同的类型，整数为int64，浮点数为float64，
+// 时长为time.Duration格式的字符串，如"5s"（整数表示秒数）。
+func normalizeDefault(v interface{}) interface{} {
+	switch d := v.(type) {
+	case time.Time:
+		return v
+	case time.Duration:
+		return d.String()
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Slice, reflect.Array:
+		s := make([]interface{}, rv.Len())
+		for i := range s {
+			s[i] = normalizeDefault(rv.Index(i).Interface())
+		}
+		return s
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return v
+		}
+		m := make(map[string]interface{}, rv.Len())
+		for _, k := range rv.MapKeys() {
+			m[k.String()] = normalizeDefault(rv.MapIndex(k).Interface())
+		}
+		return m
+	}
+	return v
+}
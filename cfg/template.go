@@ -0,0 +1,328 @@
+package cfg
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/betterjun/pkg/validation"
+)
+
+// 由配置结构生成带注释的TOML模板，v为结构体指针。
+//
+// 配置键取自toml标签，规则同Unmarshal；注释取自desc标签和valid标签；
+// 值依次取字段的非零值、default标签、零值。default标签中数组以逗号分隔，时长如"1m30s"，
+// 时间为RFC3339格式。结构体字段生成表，结构体数组生成表数组，无值时生成一项示例。
+//
+//	type Mongo struct {
+//		Servers string `toml:"servers" default:"localhost:27017" desc:"数据库地址" valid:"Required"`
+//		MaxLink int    `toml:"maxlink" default:"10" valid:"Range(1,100)"`
+//	}
+func GenerateTemplate(v interface{}) ([]byte, error) {
+	return GenerateSection("", v)
+}
+
+// 生成配置结构对应key的模板，结构体的成员位于[key]表中，key为空串时同GenerateTemplate。
+func GenerateSection(key string, v interface{}) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cfg: template requires a struct, got %T", v)
+	}
+
+	fields, err := templateFields(key, rv)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if key != "" {
+		fmt.Fprintf(&buf, "[%s]\n", key)
+	}
+	writeTemplate(&buf, key, fields)
+	return bytes.TrimLeft(buf.Bytes(), "\n"), nil
+}
+
+// 检查path处的模板是否与配置结构v生成的模板一致，不一致时返回*ErrStaleTemplate。
+func CheckTemplate(path string, v interface{}) error {
+	want, err := GenerateTemplate(v)
+	if err != nil {
+		return err
+	}
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, want) {
+		return &ErrStaleTemplate{File: path}
+	}
+	return nil
+}
+
+// 模板中的一个配置项或表。
+type templateField struct {
+	key    string
+	desc   string
+	valid  string
+	value  interface{}       // 配置项的值，table为nil时有效
+	table  []templateField   // 表的成员
+	tables [][]templateField // 表数组的各项
+}
+
+func (f *templateField) isTable() bool {
+	return f.table != nil || f.tables != nil
+}
+
+// 收集结构体字段，未指定标签的匿名结构体字段与外层合并。
+func templateFields(path string, v reflect.Value) ([]templateField, error) {
+	var fields []templateField
+	err := eachField(v, false, func(name string, f reflect.StructField, fv reflect.Value) error {
+		tf := templateField{key: name, desc: f.Tag.Get("desc"), valid: f.Tag.Get(validation.ValidTag)}
+		if err := fillTemplateField(&tf, joinKey(path, name), f, fv); err != nil {
+			return err
+		}
+		fields = append(fields, tf)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func fillTemplateField(tf *templateField, key string, f reflect.StructField, fv reflect.Value) error {
+	ft := f.Type
+	if ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+		if fv.IsNil() {
+			fv = reflect.Zero(ft)
+		} else {
+			fv = fv.Elem()
+		}
+	}
+
+	switch {
+	case ft.Kind() == reflect.Struct && ft != timeType:
+		sub, err := templateFields(key, fv)
+		if err != nil {
+			return err
+		}
+		tf.table = append([]templateField{}, sub...)
+		return nil
+
+	case ft.Kind() == reflect.Slice && isStructType(ft.Elem()):
+		if fv.Len() == 0 {
+			fv = reflect.MakeSlice(ft, 1, 1)
+		}
+		tf.tables = [][]templateField{}
+		for i := 0; i < fv.Len(); i++ {
+			ev := fv.Index(i)
+			if ev.Kind() == reflect.Ptr {
+				if ev.IsNil() {
+					ev = reflect.Zero(ev.Type().Elem())
+				} else {
+					ev = ev.Elem()
+				}
+			}
+			sub, err := templateFields(fmt.Sprintf("%s[%d]", key, i), ev)
+			if err != nil {
+				return err
+			}
+			tf.tables = append(tf.tables, sub)
+		}
+		return nil
+	}
+
+	var err error
+	if def, ok := f.Tag.Lookup("default"); ok && fv.IsZero() {
+		tf.value, err = parseTagDefault(ft, def)
+	} else {
+		tf.value, err = templateValue(fv)
+	}
+	if err != nil {
+		return fmt.Errorf("cfg: %s: %v", key, err)
+	}
+	return nil
+}
+
+func isStructType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && t != timeType
+}
+
+// 输出模板，先输出配置项，再输出表和表数组，均保持字段顺序。
+func writeTemplate(buf *bytes.Buffer, path string, fields []templateField) {
+	for _, f := range fields {
+		if f.isTable() {
+			continue
+		}
+		writeComments(buf, f)
+		s, _ := tomlValue(f.value)
+		fmt.Fprintf(buf, "%s = %s\n", tomlKey(f.key), s)
+	}
+
+	for _, f := range fields {
+		if !f.isTable() {
+			continue
+		}
+		key := joinKey(path, tomlKey(f.key))
+		if f.tables == nil {
+			buf.WriteString("\n")
+			writeComments(buf, f)
+			fmt.Fprintf(buf, "[%s]\n", key)
+			writeTemplate(buf, key, f.table)
+			continue
+		}
+		for _, t := range f.tables {
+			buf.WriteString("\n")
+			writeComments(buf, f)
+			fmt.Fprintf(buf, "[[%s]]\n", key)
+			writeTemplate(buf, key, t)
+		}
+	}
+}
+
+func writeComments(buf *bytes.Buffer, f templateField) {
+	if f.desc != "" {
+		for _, line := range strings.Split(f.desc, "\n") {
+			fmt.Fprintf(buf, "# %s\n", line)
+		}
+	}
+	if f.valid != "" {
+		fmt.Fprintf(buf, "# valid: %s\n", f.valid)
+	}
+}
+
+// 将字段值转换为配置数据的通用类型。
+func templateValue(v reflect.Value) (interface{}, error) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, fmt.Errorf("nil %s has no TOML form", v.Type())
+		}
+		return templateValue(v.Elem())
+	}
+
+	switch v.Type() {
+	case durationType:
+		return time.Duration(v.Int()).String(), nil
+	case timeType:
+		return v.Interface().(time.Time), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("value %d overflows int64", v.Uint())
+		}
+		return int64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.Slice, reflect.Array:
+		arr := make([]interface{}, v.Len())
+		for i := range arr {
+			e, err := templateValue(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = e
+		}
+		return arr, nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			break
+		}
+		m := make(map[string]interface{}, v.Len())
+		for _, k := range v.MapKeys() {
+			e, err := templateValue(v.MapIndex(k))
+			if err != nil {
+				return nil, err
+			}
+			m[k.String()] = e
+		}
+		return m, nil
+	case reflect.Struct:
+		m := make(map[string]interface{})
+		fields, err := templateFields("", v)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range fields {
+			if !f.isTable() {
+				m[f.key] = f.value
+			}
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", v.Type())
+}
+
+// 按字段类型解析default标签。
+func parseTagDefault(t reflect.Type, s string) (interface{}, error) {
+	switch t {
+	case durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("default: %v", err)
+		}
+		return d.String(), nil
+	case timeType:
+		tm, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("default: %v", err)
+		}
+		return tm, nil
+	}
+
+	var v interface{}
+	var err error
+	switch t.Kind() {
+	case reflect.String:
+		return s, nil
+	case reflect.Bool:
+		v, err = strconv.ParseBool(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err = strconv.ParseInt(s, 0, t.Bits())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		u, err = strconv.ParseUint(s, 0, t.Bits())
+		if err == nil && u > math.MaxInt64 {
+			err = fmt.Errorf("value %d overflows int64", u)
+		}
+		v = int64(u)
+	case reflect.Float32, reflect.Float64:
+		v, err = strconv.ParseFloat(s, t.Bits())
+	case reflect.Slice, reflect.Array:
+		arr := []interface{}{}
+		if strings.TrimSpace(s) != "" {
+			for _, p := range strings.Split(s, ",") {
+				e, err := parseTagDefault(t.Elem(), strings.TrimSpace(p))
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, e)
+			}
+		}
+		return arr, nil
+	default:
+		return nil, fmt.Errorf("default: unsupported type %s", t)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("default: invalid %s %q", t, s)
+	}
+	return v, nil
+}
@@ -0,0 +1,101 @@
+package cfg
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type templateSettings struct {
+	Name    string        `toml:"name" default:"app" desc:"服务名"`
+	Debug   bool          `toml:"debug"`
+	Timeout time.Duration `toml:"timeout" default:"1m30s"`
+	Tags    []string      `toml:"tags" default:"a, b"`
+	Mongo   struct {
+		Servers string `toml:"servers" default:"localhost:27017" desc:"数据库地址" valid:"Required"`
+		MaxLink int    `toml:"maxlink" default:"10" valid:"Range(1,100)"`
+	} `toml:"mongo" desc:"MongoDB"`
+	Backends []struct {
+		Host   string `toml:"host" default:"127.0.0.1"`
+		Weight int    `toml:"weight" default:"1"`
+	} `toml:"backends"`
+	Internal string `toml:"-"`
+}
+
+const templateWant = `# 服务名
+name = "app"
+debug = false
+timeout = "1m30s"
+tags = ["a", "b"]
+
+# MongoDB
+[mongo]
+# 数据库地址
+# valid: Required
+servers = "localhost:27017"
+# valid: Range(1,100)
+maxlink = 10
+
+[[backends]]
+host = "127.0.0.1"
+weight = 1
+`
+
+func TestGenerateTemplate(t *testing.T) {
+	b, err := GenerateTemplate(&templateSettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != templateWant {
+		t.Errorf("GenerateTemplate =\n%s\nwant\n%s", b, templateWant)
+	}
+
+	dir := tempDir(t)
+	path := writeFile(t, dir, "sample.toml", string(b))
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s templateSettings
+	if err := c.UnmarshalAll(&s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Timeout != 90*time.Second || s.Mongo.MaxLink != 10 || !reflect.DeepEqual(s.Tags, []string{"a", "b"}) {
+		t.Errorf("template does not load back: %+v", s)
+	}
+
+	// 字段的非零值优先于default标签
+	s = templateSettings{Name: "svc"}
+	b, err = GenerateTemplate(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), "# 服务名\nname = \"svc\"\n") {
+		t.Errorf("field value should override default tag:\n%s", b)
+	}
+}
+
+func TestCheckTemplate(t *testing.T) {
+	dir := tempDir(t)
+	path := writeFile(t, dir, "sample.toml", templateWant)
+	if err := CheckTemplate(path, &templateSettings{}); err != nil {
+		t.Errorf("CheckTemplate = %v", err)
+	}
+
+	writeFile(t, dir, "sample.toml", "name = \"app\"\n")
+	var se *ErrStaleTemplate
+	if err := CheckTemplate(path, &templateSettings{}); !errors.As(err, &se) {
+		t.Errorf("CheckTemplate = %v", err)
+	}
+}
+
+func TestGenerateTemplateBadDefault(t *testing.T) {
+	var s struct {
+		N int `toml:"n" default:"ten"`
+	}
+	if _, err := GenerateTemplate(&s); err == nil {
+		t.Error("invalid default should fail")
+	}
+}
@@ -0,0 +1,128 @@
+package cfg
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/betterjun/pkg/validation"
+)
+
+// 配置校验失败，Errors列出所有违反的规则，每项以配置键路径开头。
+type ErrValidation struct {
+	Errors []string
+}
+
+func (e *ErrValidation) Error() string {
+	return "cfg: validation failed: " + strings.Join(e.Errors, "; ")
+}
+
+// 将key对应的配置子树解码到target中，并按字段的valid标签校验，
+// 如`valid:"Required;Range(1,100)"`，规则见validation.ValidationGroup.ValidateTag。
+// 校验不通过时返回*ErrValidation，列出所有错误。
+func (c *Config) UnmarshalAndValidate(key string, target interface{}) error {
+	if err := c.Unmarshal(key, target); err != nil {
+		return err
+	}
+	return Validate(key, target)
+}
+
+// 注册配置结构，schema为结构体指针，仅用于获取类型。
+// 此后每次载入配置时，key对应的配置子树须能解码到该结构并通过校验，否则载入失败，原配置保持不变。
+func (c *Config) RegisterSchema(key string, schema interface{}) {
+	t := reflect.TypeOf(schema)
+	if t == nil || t.Kind() != reflect.Ptr {
+		panic("cfg: schema must be a pointer")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.schemas == nil {
+		c.schemas = make(map[string]reflect.Type)
+	}
+	c.schemas[key] = t.Elem()
+}
+
+// 按注册的配置结构校验快照。
+func (c *Config) checkSchemas(snap *snapshot) error {
+	c.mu.Lock()
+	schemas := make(map[string]reflect.Type, len(c.schemas))
+	for k, t := range c.schemas {
+		schemas[k] = t
+	}
+	c.mu.Unlock()
+
+	var errs []string
+	for key, t := range schemas {
+		target := reflect.New(t).Interface()
+		err := c.unmarshal(snap, key, target)
+		if err == nil {
+			err = Validate(key, target)
+		}
+		if ve, ok := err.(*ErrValidation); ok {
+			errs = append(errs, ve.Errors...)
+		} else if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return &ErrValidation{Errors: errs}
+	}
+	return nil
+}
+
+// 按valid标签校验结构体，key为结构体对应的配置键，用于错误信息。
+func Validate(key string, target interface{}) error {
+	vg := &validation.ValidationGroup{}
+	var errs []string
+	validateValue(vg, key, reflect.ValueOf(target), &errs)
+	errs = append(errs, vg.GetErrors()...)
+	if len(errs) > 0 {
+		return &ErrValidation{Errors: errs}
+	}
+	return nil
+}
+
+func validateValue(vg *validation.ValidationGroup, path string, v reflect.Value, errs *[]string) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return
+		}
+		validateStruct(vg, path, v, errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validateValue(vg, fmt.Sprintf("%s[%d]", path, i), v.Index(i), errs)
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			if k.Kind() == reflect.String {
+				validateValue(vg, joinKey(path, k.String()), v.MapIndex(k), errs)
+			}
+		}
+	}
+}
+
+func validateStruct(vg *validation.ValidationGroup, path string, v reflect.Value, errs *[]string) {
+	eachField(v, false, func(name string, f reflect.StructField, fv reflect.Value) error {
+		key := joinKey(path, name)
+		if tag := f.Tag.Get(validation.ValidTag); tag != "" {
+			var obj interface{}
+			if ev := reflect.Indirect(fv); ev.IsValid() {
+				obj = ev.Interface()
+			}
+			if _, err := vg.ValidateTag(obj, key, tag); err != nil {
+				*errs = append(*errs, fmt.Sprintf("%s invalid rule: %v", key, err))
+			}
+		}
+		validateValue(vg, key, fv, errs)
+		return nil
+	})
+}
@@ -0,0 +1,82 @@
+package cfg
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+type poolSettings struct {
+	Servers string `toml:"servers" valid:"Required"`
+	MaxLink int    `toml:"maxlink" valid:"Range(1,100)"`
+	Admin   string `toml:"admin" valid:"Email"`
+	Hosts   []struct {
+		IP string `toml:"ip" valid:"IP"`
+	} `toml:"hosts"`
+}
+
+func TestUnmarshalAndValidate(t *testing.T) {
+	dir := tempDir(t)
+	c, err := Load(writeFile(t, dir, "a.toml", `
+[good]
+servers = "127.0.0.1"
+maxlink = 10
+admin = "ops@example.com"
+[[good.hosts]]
+ip = "10.0.0.1"
+
+[bad]
+maxlink = 500
+admin = "nobody"
+[[bad.hosts]]
+ip = "10.0.0.1"
+[[bad.hosts]]
+ip = "localhost"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var s poolSettings
+	if err := c.UnmarshalAndValidate("good", &s); err != nil {
+		t.Error(err)
+	}
+
+	var b poolSettings
+	err = c.UnmarshalAndValidate("bad", &b)
+	var ve *ErrValidation
+	if !errors.As(err, &ve) {
+		t.Fatalf("expected ErrValidation, got %v", err)
+	}
+	want := []string{
+		"bad.admin Must be a valid email address",
+		"bad.hosts[1].ip Must be a valid ip address",
+		"bad.maxlink Range is 1 to 100",
+		"bad.servers Can not be empty",
+	}
+	got := append([]string(nil), ve.Errors...)
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("errors = %q", got)
+	}
+}
+
+func TestRegisterSchema(t *testing.T) {
+	dir := tempDir(t)
+	file := writeFile(t, dir, "a.toml", "[mongo]\nservers = \"a\"\nmaxlink = 10\n")
+
+	c := New()
+	c.RegisterSchema("mongo", (*poolSettings)(nil))
+	if err := c.LoadConfig(file); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, dir, "a.toml", "[mongo]\nservers = \"a\"\nmaxlink = 0\n")
+	if err := c.Reload(); err == nil {
+		t.Error("invalid config should be rejected")
+	}
+	if c.GetInt("mongo.maxlink") != 10 {
+		t.Error("last good config should stay live")
+	}
+}
@@ -0,0 +1,183 @@
+package cfg
+
+import (
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// 配置项句柄的缓存值。
+type varState struct {
+	version uint64
+	value   interface{}
+}
+
+// 配置项句柄，缓存按类型解析后的值，配置变化后首次读取时重新解析。
+type watchVar struct {
+	c     *Config
+	key   string
+	def   interface{}
+	get   func() (interface{}, error)
+	state atomic.Value // *varState
+}
+
+func newVar(c *Config, key string, def interface{}, get func() (interface{}, error)) *watchVar {
+	v := &watchVar{c: c, key: key, def: def, get: get}
+	v.state.Store(&varState{version: ^uint64(0)})
+	return v
+}
+
+// 返回配置项的值，不加锁。配置项不存在或类型不符时返回默认值。
+func (v *watchVar) load() interface{} {
+	ver := atomic.LoadUint64(&v.c.version)
+	if st := v.state.Load().(*varState); st.version == ver {
+		return st.value
+	}
+	val, err := v.get()
+	if err != nil {
+		val = v.def
+	}
+	v.state.Store(&varState{version: ver, value: val})
+	return val
+}
+
+// 重新载入后配置项的值发生变化时回调fn。
+func (v *watchVar) watch(fn func(old, new interface{})) (cancel func()) {
+	last := v.load()
+	return v.c.OnChange(v.key, func(_, _ interface{}) {
+		// 回调串行执行，last无需加锁
+		val := v.load()
+		if !reflect.DeepEqual(last, val) {
+			old := last
+			last = val
+			fn(old, val)
+		}
+	})
+}
+
+// 字符串配置项的句柄，见Config.StringVar。
+type StringValue struct{ v *watchVar }
+
+// 返回字符串配置项key的句柄，配置项不存在或类型不符时取值为def。
+func (c *Config) StringVar(key string, def string) *StringValue {
+	return &StringValue{newVar(c, key, def, func() (interface{}, error) { return c.GetStringE(key) })}
+}
+
+// 返回配置项当前的值，不加锁，可以在热点路径中使用。
+func (s *StringValue) Load() string {
+	return s.v.load().(string)
+}
+
+// 重新载入后配置项的值发生变化时回调fn，返回值用于取消。
+func (s *StringValue) Watch(fn func(old, new string)) (cancel func()) {
+	return s.v.watch(func(old, new interface{}) { fn(old.(string), new.(string)) })
+}
+
+// 整数配置项的句柄，见Config.IntVar。
+type IntValue struct{ v *watchVar }
+
+// 返回整数配置项key的句柄，配置项不存在、类型不符或超出范围时取值为def。
+func (c *Config) IntVar(key string, def int) *IntValue {
+	return &IntValue{newVar(c, key, def, func() (interface{}, error) { return c.GetIntE(key) })}
+}
+
+// 返回配置项当前的值，不加锁，可以在热点路径中使用。
+func (i *IntValue) Load() int {
+	return i.v.load().(int)
+}
+
+// 重新载入后配置项的值发生变化时回调fn，返回值用于取消。
+func (i *IntValue) Watch(fn func(old, new int)) (cancel func()) {
+	return i.v.watch(func(old, new interface{}) { fn(old.(int), new.(int)) })
+}
+
+// 64位整数配置项的句柄，见Config.Int64Var。
+type Int64Value struct{ v *watchVar }
+
+// 返回64位整数配置项key的句柄，配置项不存在或类型不符时取值为def。
+func (c *Config) Int64Var(key string, def int64) *Int64Value {
+	return &Int64Value{newVar(c, key, def, func() (interface{}, error) { return c.GetInt64E(key) })}
+}
+
+// 返回配置项当前的值，不加锁，可以在热点路径中使用。
+func (i *Int64Value) Load() int64 {
+	return i.v.load().(int64)
+}
+
+// 重新载入后配置项的值发生变化时回调fn，返回值用于取消。
+func (i *Int64Value) Watch(fn func(old, new int64)) (cancel func()) {
+	return i.v.watch(func(old, new interface{}) { fn(old.(int64), new.(int64)) })
+}
+
+// 浮点数配置项的句柄，见Config.Float64Var。
+type Float64Value struct{ v *watchVar }
+
+// 返回64位浮点数配置项key的句柄，配置项不存在或类型不符时取值为def。
+func (c *Config) Float64Var(key string, def float64) *Float64Value {
+	return &Float64Value{newVar(c, key, def, func() (interface{}, error) { return c.GetFloat64E(key) })}
+}
+
+// 返回配置项当前的值，不加锁，可以在热点路径中使用。
+func (f *Float64Value) Load() float64 {
+	return f.v.load().(float64)
+}
+
+// 重新载入后配置项的值发生变化时回调fn，返回值用于取消。
+func (f *Float64Value) Watch(fn func(old, new float64)) (cancel func()) {
+	return f.v.watch(func(old, new interface{}) { fn(old.(float64), new.(float64)) })
+}
+
+// 布尔配置项的句柄，见Config.BoolVar。
+type BoolValue struct{ v *watchVar }
+
+// 返回布尔配置项key的句柄，配置项不存在或类型不符时取值为def。
+func (c *Config) BoolVar(key string, def bool) *BoolValue {
+	return &BoolValue{newVar(c, key, def, func() (interface{}, error) { return c.GetBoolE(key) })}
+}
+
+// 返回配置项当前的值，不加锁，可以在热点路径中使用。
+func (b *BoolValue) Load() bool {
+	return b.v.load().(bool)
+}
+
+// 重新载入后配置项的值发生变化时回调fn，返回值用于取消。
+func (b *BoolValue) Watch(fn func(old, new bool)) (cancel func()) {
+	return b.v.watch(func(old, new interface{}) { fn(old.(bool), new.(bool)) })
+}
+
+// 时长配置项的句柄，见Config.DurationVar。
+type DurationValue struct{ v *watchVar }
+
+// 返回时长配置项key的句柄，解析规则同GetDuration，配置项不存在或类型不符时取值为def。
+func (c *Config) DurationVar(key string, def time.Duration) *DurationValue {
+	return &DurationValue{newVar(c, key, def, func() (interface{}, error) { return c.GetDurationE(key) })}
+}
+
+// 返回配置项当前的值，不加锁，可以在热点路径中使用。
+func (d *DurationValue) Load() time.Duration {
+	return d.v.load().(time.Duration)
+}
+
+// 重新载入后配置项的值发生变化时回调fn，返回值用于取消。
+func (d *DurationValue) Watch(fn func(old, new time.Duration)) (cancel func()) {
+	return d.v.watch(func(old, new interface{}) { fn(old.(time.Duration), new.(time.Duration)) })
+}
+
+// 字符串数组配置项的句柄，见Config.StringSliceVar。
+type StringSliceValue struct{ v *watchVar }
+
+// 返回字符串数组配置项key的句柄，配置项不存在或类型不符时取值为def。
+// Load返回的切片为多次调用共享，不能修改。
+func (c *Config) StringSliceVar(key string, def []string) *StringSliceValue {
+	return &StringSliceValue{newVar(c, key, def, func() (interface{}, error) { return c.GetStringSliceE(key) })}
+}
+
+// 返回配置项当前的值，不加锁，可以在热点路径中使用。
+func (s *StringSliceValue) Load() []string {
+	return s.v.load().([]string)
+}
+
+// 重新载入后配置项的值发生变化时回调fn，返回值用于取消。
+func (s *StringSliceValue) Watch(fn func(old, new []string)) (cancel func()) {
+	return s.v.watch(func(old, new interface{}) { fn(old.([]string), new.([]string)) })
+}
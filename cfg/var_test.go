@@ -0,0 +1,120 @@
+package cfg
+
+import (
+	"flag"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestVar(t *testing.T) {
+	c := New()
+	maxLink := c.IntVar("mongo.maxlink", 10)
+	timeout := c.DurationVar("mongo.timeout", time.Second)
+	hosts := c.StringSliceVar("mongo.hosts", nil)
+	if v := maxLink.Load(); v != 10 {
+		t.Errorf("before load maxlink = %d", v)
+	}
+
+	dir := tempDir(t)
+	file := writeFile(t, dir, "app.toml", "[mongo]\nmaxlink = 20\ntimeout = \"5s\"\nhosts = [\"a\"]\n")
+	if err := c.LoadConfig(file); err != nil {
+		t.Fatal(err)
+	}
+	if v := maxLink.Load(); v != 20 {
+		t.Errorf("maxlink = %d", v)
+	}
+	if v := timeout.Load(); v != 5*time.Second {
+		t.Errorf("timeout = %v", v)
+	}
+	if v := hosts.Load(); len(v) != 1 || v[0] != "a" {
+		t.Errorf("hosts = %v", v)
+	}
+
+	type change struct{ old, new int }
+	var changes []change
+	cancel := maxLink.Watch(func(old, new int) { changes = append(changes, change{old, new}) })
+	cancel2 := timeout.Watch(func(old, new time.Duration) { t.Errorf("timeout changed %v -> %v", old, new) })
+
+	writeFile(t, dir, "app.toml", "[mongo]\nmaxlink = 30\ntimeout = \"5s\"\n")
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	// 配置项被删除后恢复为默认值
+	writeFile(t, dir, "app.toml", "[mongo]\ntimeout = 5\n")
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if v := maxLink.Load(); v != 10 {
+		t.Errorf("removed maxlink = %d, want default", v)
+	}
+	if v := hosts.Load(); v != nil {
+		t.Errorf("removed hosts = %v", v)
+	}
+	if len(changes) != 2 || changes[0] != (change{20, 30}) || changes[1] != (change{30, 10}) {
+		t.Errorf("changes = %v", changes)
+	}
+
+	cancel()
+	cancel2()
+	writeFile(t, dir, "app.toml", "[mongo]\nmaxlink = 40\n")
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 {
+		t.Errorf("canceled watch called: %v", changes)
+	}
+
+	// 命令行参数设置的值无需重新载入即生效
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := c.BindFlags(fs, "mongo.maxlink"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse([]string{"--mongo.maxlink=50"}); err != nil {
+		t.Fatal(err)
+	}
+	if v := maxLink.Load(); v != 50 {
+		t.Errorf("flag maxlink = %d", v)
+	}
+}
+
+func TestVarConcurrent(t *testing.T) {
+	dir := tempDir(t)
+	file := writeFile(t, dir, "app.toml", "n = 1\n")
+	c, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := c.Int64Var("n", 0)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if v := n.Load(); v < 1 || v > 2 {
+					t.Errorf("n = %d", v)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		writeFile(t, dir, "app.toml", []string{"n = 1\n", "n = 2\n"}[i%2])
+		if err := c.Reload(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+	if v := n.Load(); v != 2 {
+		t.Errorf("n = %d after reloads", v)
+	}
+}
@@ -0,0 +1,272 @@
+package cfg
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+type subscriber struct {
+	prefix string
+	fn     func(old, new interface{})
+}
+
+// 注册配置变化回调，prefix为配置键前缀，空串表示整个配置。
+// 重新载入后，prefix对应的配置发生变化时回调fn，old和new为变化前后的值（不存在时为nil），
+// 表以map[string]interface{}形式给出。返回值用于取消订阅。
+func (c *Config) OnChange(prefix string, fn func(old, new interface{})) (cancel func()) {
+	s := &subscriber{prefix: prefix, fn: fn}
+
+	c.mu.Lock()
+	c.subscribers = append(c.subscribers, s)
+	c.mu.Unlock()
+
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for i, v := range c.subscribers {
+			if v == s {
+				c.subscribers = append(c.subscribers[:i:i], c.subscribers[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// 重新读取当前配置文件。文件有误时返回错误，原配置保持不变。
+// 新获取的远程文档参与载入，通过校验后生效；去掉新文档后可以载入时，
+// 丢弃新文档并应用其余的变化，同样返回错误。
+// 订阅者在释放重新载入的锁之后按变化顺序依次回调，回调中可以再次调用Reload；
+// 其他回调正在进行时，变化交给正在通知的goroutine，Reload不等待回调完成即返回。
+func (c *Config) Reload() error {
+	err := c.reload()
+	c.flushChanges()
+	return err
+}
+
+func (c *Config) reload() error {
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+
+	c.mu.Lock()
+	files, strict := c.files, c.strict
+	remotes := c.remoteDocs(true)
+	c.mu.Unlock()
+
+	snap, err := c.build(files, remotes, strict)
+	if err == nil {
+		c.apply(snap)
+		c.commitRemotes(remotes)
+		return nil
+	}
+
+	pending := false
+	for _, d := range remotes {
+		pending = pending || d.pending
+	}
+	if !pending {
+		return err
+	}
+	c.mu.Lock()
+	committed := c.remoteDocs(false)
+	c.mu.Unlock()
+	if snap, rerr := c.build(files, committed, strict); rerr == nil {
+		c.rejectRemotes(remotes)
+		c.apply(snap)
+	}
+	return err
+}
+
+// 载入配置文件和远程文档并校验。
+func (c *Config) build(files []string, remotes []remoteDoc, strict bool) (*snapshot, error) {
+	snap, err := c.loadFiles(files, remotes)
+	if err != nil {
+		return nil, err
+	}
+	if strict {
+		if err := c.checkRequired(snap); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.checkSchemas(snap); err != nil {
+		return nil, err
+	}
+	if err := c.checkJSONSchemas(snap); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// 使快照生效，记录变化，等待通知订阅者。
+func (c *Config) apply(snap *snapshot) {
+	old := c.getSnapshot()
+	c.current.Store(snap)
+	atomic.AddUint64(&c.version, 1)
+	c.audit(old, snap)
+
+	c.mu.Lock()
+	c.changes = append(c.changes, [2]*snapshot{old, snap})
+	c.mu.Unlock()
+}
+
+// 按顺序通知等待的变化。已有goroutine在通知时直接返回，由其继续处理新的变化。
+func (c *Config) flushChanges() {
+	c.mu.Lock()
+	if c.notifying {
+		c.mu.Unlock()
+		return
+	}
+	c.notifying = true
+	// 回调panic时也要复位，否则之后的变化不再通知
+	defer func() {
+		c.mu.Lock()
+		c.notifying = false
+		c.mu.Unlock()
+	}()
+
+	for len(c.changes) > 0 {
+		ch := c.changes[0]
+		c.changes = c.changes[1:]
+		c.mu.Unlock()
+		c.notify(ch[0], ch[1])
+		c.mu.Lock()
+	}
+	c.mu.Unlock()
+}
+
+// 通知前缀下配置发生变化的订阅者。
+func (c *Config) notify(old, new *snapshot) {
+	c.mu.Lock()
+	subs := make([]*subscriber, len(c.subscribers))
+	copy(subs, c.subscribers)
+	c.mu.Unlock()
+
+	for _, s := range subs {
+		o, n := c.subtree(old, s.prefix), c.subtree(new, s.prefix)
+		if !reflect.DeepEqual(o, n) {
+			s.fn(o, n)
+		}
+	}
+}
+
+func (c *Config) subtree(snap *snapshot, key string) interface{} {
+	if snap == nil {
+		return nil
+	}
+	v, ok := lookup(snap.data, key)
+	if !ok {
+		return nil
+	}
+	return c.overlay(key, v)
+}
+
+// 文件变化后等待其稳定的最长时间，见Watch。
+const maxSettle = 100 * time.Millisecond
+
+// Watch的默认轮询间隔。
+const DefaultWatchInterval = time.Second
+
+// 以interval为间隔轮询配置文件和远程配置源，任一变化时重新载入。停止监视时取消进行中的拉取。
+// 发现文件变化后等待一小段时间（interval和100ms中较小者）再次检查，
+// 文件仍在变化时（如编辑器先截断再写入）推迟到下次轮询，避免载入写了一半的文件。
+// 载入失败时原配置继续生效，错误交给onError处理（可以为nil）。返回值用于停止监视。
+// interval不大于0时使用DefaultWatchInterval。
+func (c *Config) Watch(interval time.Duration, onError func(error)) (stop func()) {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	settle := interval
+	if settle > maxSettle {
+		settle = maxSettle
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := ctx.Done()
+	last := c.fileStamps()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			changed, err := c.fetchRemotes(ctx)
+			if err != nil && onError != nil {
+				onError(err)
+			}
+			stamps := c.fileStamps()
+			if !changed && reflect.DeepEqual(stamps, last) {
+				continue
+			}
+			// 文件可能正在写入，修改标记稳定后再载入，否则留到下次检查
+			if !reflect.DeepEqual(stamps, last) && !c.settled(stamps, settle, done) {
+				continue
+			}
+			last = stamps
+
+			if err := c.Reload(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}()
+
+	return cancel
+}
+
+// 等待settle后重新检查文件，修改标记与stamps一致时返回true，停止监视时返回false。
+func (c *Config) settled(stamps []stamp, settle time.Duration, done <-chan struct{}) bool {
+	timer := time.NewTimer(settle)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return false
+	case <-timer.C:
+	}
+	return reflect.DeepEqual(c.fileStamps(), stamps)
+}
+
+// 文件修改标记，文件变化时标记随之改变。
+type stamp struct {
+	file    string
+	modTime time.Time
+	size    int64
+}
+
+// 返回所有需要监视的文件的修改标记，包括被包含的文件，
+// 以及当前匹配include文件模式的文件，以便发现新增和删除的文件。
+func (c *Config) fileStamps() []stamp {
+	c.mu.Lock()
+	files := c.files
+	c.mu.Unlock()
+
+	if snap := c.getSnapshot(); snap != nil {
+		files = append(files[:len(files):len(files)], snap.files...)
+		for _, pattern := range snap.globs {
+			matches, _ := filepath.Glob(pattern)
+			files = append(files, matches...)
+		}
+	}
+
+	seen := make(map[string]bool, len(files))
+	stamps := make([]stamp, 0, len(files))
+	for _, file := range files {
+		if seen[file] {
+			continue
+		}
+		seen[file] = true
+		st := stamp{file: file}
+		if fi, err := os.Stat(file); err == nil {
+			st.modTime, st.size = fi.ModTime(), fi.Size()
+		}
+		stamps = append(stamps, st)
+	}
+	sort.Slice(stamps, func(i, j int) bool { return stamps[i].file < stamps[j].file })
+	return stamps
+}
@@ -0,0 +1,118 @@
+package cfg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReloadAndOnChange(t *testing.T) {
+	dir := tempDir(t)
+	file := writeFile(t, dir, "a.toml", "[mongo]\nmaxlink = 10\n[other]\nv = 1\n")
+	if err := LoadConfig(file); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := make(chan [2]interface{}, 4)
+	cancel := OnChange("mongo", func(old, new interface{}) {
+		changes <- [2]interface{}{old, new}
+	})
+	defer cancel()
+
+	others := 0
+	defer OnChange("other", func(old, new interface{}) { others++ })()
+
+	writeFile(t, dir, "a.toml", "[mongo]\nmaxlink = 20\n[other]\nv = 1\n")
+	if err := Reload(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case c := <-changes:
+		old := c[0].(map[string]interface{})
+		new := c[1].(map[string]interface{})
+		if old["maxlink"] != int64(10) || new["maxlink"] != int64(20) {
+			t.Errorf("unexpected change %v -> %v", old, new)
+		}
+	default:
+		t.Error("OnChange not called")
+	}
+	if others != 0 {
+		t.Error("unchanged prefix should not be notified")
+	}
+
+	// 文件有误时保留原配置。
+	writeFile(t, dir, "a.toml", "[mongo\nmaxlink = ")
+	if err := Reload(); err == nil {
+		t.Error("broken file should be rejected")
+	}
+	if GetInt("mongo.maxlink") != 20 {
+		t.Error("last good config should stay live")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := tempDir(t)
+	file := writeFile(t, dir, "a.toml", "v = 1\n")
+	if err := LoadConfig(file); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := make(chan struct{}, 1)
+	defer OnChange("v", func(old, new interface{}) { changed <- struct{}{} })()
+
+	stop := Watch(10*time.Millisecond, nil)
+	defer stop()
+
+	time.Sleep(20 * time.Millisecond)
+	writeFile(t, dir, "a.toml", "v = 22\n")
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("watch did not reload")
+	}
+	if GetInt("v") != 22 {
+		t.Errorf("v = %d", GetInt("v"))
+	}
+}
+
+func TestReloadInCallback(t *testing.T) {
+	dir := tempDir(t)
+	file := writeFile(t, dir, "a.toml", "v = 1\n")
+	c, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 回调中修改文件并再次载入，变化按顺序通知
+	var seen []int64
+	c.OnChange("v", func(old, new interface{}) {
+		seen = append(seen, new.(int64))
+		if new == int64(2) {
+			writeFile(t, dir, "a.toml", "v = 3\n")
+			if err := c.Reload(); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		writeFile(t, dir, "a.toml", "v = 2\n")
+		if err := c.Reload(); err != nil {
+			t.Error(err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Reload in OnChange deadlocked")
+	}
+	if len(seen) != 2 || seen[0] != 2 || seen[1] != 3 {
+		t.Errorf("changes = %v", seen)
+	}
+
+	// 间隔不大于0时使用默认间隔
+	stop := c.Watch(0, nil)
+	time.Sleep(10 * time.Millisecond)
+	stop()
+}
@@ -0,0 +1,11 @@
+// cfgctl查看、校验和比较配置文件，用法见cfgctl help。
+// 未注册配置结构，validate需要以-schema指定JSON Schema文件。
+package main
+
+import (
+	"github.com/betterjun/pkg/cfg/cfgctl"
+)
+
+func main() {
+	cfgctl.Main(nil)
+}
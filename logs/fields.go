@@ -0,0 +1,119 @@
+package logs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 日志字段。
+type field struct {
+	key   string
+	value interface{}
+}
+
+// 带字段的日志对象，由With创建，零值可用。
+// 方法的参数为消息和附加的字段，不做格式化，可以在多个goroutine中同时使用。
+type Logger struct {
+	fields []field
+}
+
+// 返回带字段的日志对象，kv为交替的键和值，如With("user_id", id, "order", oid).Info("paid")。
+// 键不是字符串时以fmt.Sprint转换，缺少值的键以"!BADKEY"为键、自身为值。
+func With(kv ...interface{}) *Logger {
+	return &Logger{fields: appendFields(nil, kv)}
+}
+
+// 返回子日志对象，继承l的字段并追加kv，与l同名的字段取kv中的值。
+func (l *Logger) With(kv ...interface{}) *Logger {
+	return &Logger{fields: appendFields(l.fields, kv)}
+}
+
+// 输出跟踪信息。
+func (l *Logger) Trace(msg string, kv ...interface{}) {
+	output(levelTrace, false, 1, msg, appendFields(l.fields, kv))
+}
+
+// 输出调试信息。
+func (l *Logger) Debug(msg string, kv ...interface{}) {
+	output(levelDebug, false, 1, msg, appendFields(l.fields, kv))
+}
+
+// 输出运行信息。
+func (l *Logger) Info(msg string, kv ...interface{}) {
+	output(levelInfo, false, 1, msg, appendFields(l.fields, kv))
+}
+
+// 输出警告消息。
+func (l *Logger) Warn(msg string, kv ...interface{}) {
+	output(levelWarn, false, 1, msg, appendFields(l.fields, kv))
+}
+
+// 输出错误消息，同时记录在错误日志中。
+func (l *Logger) Error(msg string, kv ...interface{}) {
+	output(levelError, false, 1, msg, appendFields(l.fields, kv))
+}
+
+// 输出危险消息，同时记录在错误日志中。
+func (l *Logger) Critical(msg string, kv ...interface{}) {
+	output(levelCritical, false, 1, msg, appendFields(l.fields, kv))
+}
+
+// 输出Http restful请求消息，在单独的日志文件中记录。
+func (l *Logger) LogRequest(msg string, kv ...interface{}) {
+	output(levelDebug, true, 1, msg, appendFields(l.fields, kv))
+}
+
+// 复制fields并追加kv，同名字段替换原值，保持首次出现的顺序。
+func appendFields(fields []field, kv []interface{}) []field {
+	if len(kv) == 0 {
+		return fields
+	}
+	out := make([]field, len(fields), len(fields)+(len(kv)+1)/2)
+	copy(out, fields)
+next:
+	for i := 0; i < len(kv); i += 2 {
+		var f field
+		if i+1 < len(kv) {
+			key, ok := kv[i].(string)
+			if !ok {
+				key = fmt.Sprint(kv[i])
+			}
+			f = field{key, kv[i+1]}
+		} else {
+			f = field{"!BADKEY", kv[i]}
+		}
+		for j := range out {
+			if out[j].key == f.key {
+				out[j].value = f.value
+				continue next
+			}
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// 文本格式的字段，如" user_id=1 name=\"a b\""。
+func formatFields(fields []field) string {
+	var b strings.Builder
+	for _, f := range fields {
+		b.WriteByte(' ')
+		b.WriteString(f.key)
+		b.WriteByte('=')
+		var s string
+		switch v := f.value.(type) {
+		case string:
+			s = v
+		case error:
+			s = v.Error()
+		default:
+			s = fmt.Sprint(v)
+		}
+		if s == "" || strings.ContainsAny(s, " \t\r\n\"=") {
+			s = strconv.Quote(s)
+		}
+		b.WriteString(s)
+	}
+	return b.String()
+}
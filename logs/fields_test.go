@@ -0,0 +1,138 @@
+package logs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWithText(t *testing.T) {
+	var buf bytes.Buffer
+	saved := stderr
+	stderr = log.New(&buf, "", 0)
+	defer func() { stderr = saved }()
+
+	parent := With("user_id", 7, "order", "a b")
+	parent.With("order", 9, "err", errors.New("boom")).Info("paid", "extra")
+	parent.Warn("slow")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines: %q", len(lines), buf.String())
+	}
+	if !strings.HasPrefix(lines[0], "[I] [fields_test.go:") ||
+		!strings.HasSuffix(lines[0], "paid user_id=7 order=9 err=boom !BADKEY=extra") {
+		t.Errorf("child line = %q", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], `slow user_id=7 order="a b"`) {
+		t.Errorf("parent line = %q", lines[1])
+	}
+}
+
+func TestWithJSON(t *testing.T) {
+	dir := t.TempDir()
+	if err := Init(Options{Dir: dir, Format: "json"}); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+
+	l := With("user_id", 7)
+	l.With("order", "o1").Error("paid", "msg", "dup")
+	Info("plain %d", 1)
+	l.LogRequest("GET /")
+	Close()
+
+	read := func(name string) []map[string]interface{} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			var m map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &m); err != nil {
+				t.Fatalf("%s: %v: %q", name, err, line)
+			}
+			out = append(out, m)
+		}
+		return out
+	}
+
+	sys := read("sys_log.txt")
+	if len(sys) != 2 {
+		t.Fatalf("sys_log.txt has %d lines, want 2", len(sys))
+	}
+	e := sys[0]
+	if e["level"] != "error" || e["msg"] != "paid" || e["user_id"] != float64(7) ||
+		e["order"] != "o1" || e["fields.msg"] != "dup" {
+		t.Errorf("entry = %v", e)
+	}
+	if c, _ := e["caller"].(string); !strings.HasPrefix(c, "fields_test.go:") {
+		t.Errorf("caller = %q", c)
+	}
+	if _, ok := e["ts"].(string); !ok {
+		t.Errorf("ts missing: %v", e)
+	}
+	if sys[1]["msg"] != "plain 1" || sys[1]["level"] != "info" {
+		t.Errorf("printf entry = %v", sys[1])
+	}
+	if errs := read("err_log.txt"); len(errs) != 1 || errs[0]["msg"] != "paid" {
+		t.Errorf("err_log.txt = %v", errs)
+	}
+	if reqs := read("req_log.txt"); len(reqs) != 1 || reqs[0]["msg"] != "GET /" || reqs[0]["user_id"] != float64(7) {
+		t.Errorf("req_log.txt = %v", reqs)
+	}
+}
+
+func TestJSONLevel(t *testing.T) {
+	dir := t.TempDir()
+	if err := Init(Options{Dir: dir, Format: "json", Level: "warn"}); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+
+	Info("dropped")
+	With("k", 1).Debug("dropped")
+	Warn("kept")
+	LogRequest("request")
+	GetSysLogger().Info("dropped")
+	GetSysLogger().Error("via beego %d", 1)
+	Close()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "sys_log.txt"))
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || strings.Contains(string(data), "dropped") {
+		t.Fatalf("sys_log.txt =\n%s", data)
+	}
+	var e map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[1]), &e); err != nil || e["msg"] != "via beego 1" || e["level"] != "error" {
+		t.Errorf("GetSysLogger entry = %v, %v", e, err)
+	}
+	if c, _ := e["caller"].(string); !strings.HasPrefix(c, "fields_test.go:") {
+		t.Errorf("GetSysLogger caller = %q", c)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "err_log.txt")); !strings.Contains(string(data), "via beego 1") {
+		t.Errorf("err_log.txt =\n%s", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "req_log.txt")); !strings.Contains(string(data), "request") {
+		t.Errorf("request logs should ignore Level, req_log.txt =\n%s", data)
+	}
+}
+
+func TestInitFormat(t *testing.T) {
+	if err := Init(Options{Format: "xml", Console: true}); err == nil {
+		t.Error("Init accepted unknown format")
+	}
+	if err := Init(Options{Level: "loud", Console: true}); err == nil {
+		t.Error("Init accepted unknown level")
+	}
+	if err := Init(Options{Format: "json"}); err == nil {
+		Close()
+		t.Error("Init accepted JSON options without output")
+	}
+}
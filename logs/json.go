@@ -0,0 +1,160 @@
+package logs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+)
+
+// 一条日志。
+type entry struct {
+	time   time.Time
+	level  level
+	caller string // 文件名:行号
+	msg    string
+	fields []field
+}
+
+// 固定输出的键，与之同名的字段加上"fields."前缀。
+var reservedKeys = map[string]bool{"ts": true, "level": true, "caller": true, "msg": true}
+
+// 将日志编码为一行JSON，如
+//
+//	{"ts":"2006-01-02T15:04:05.999+08:00","level":"info","caller":"pay.go:42","msg":"paid","user_id":1}
+//
+// 字段按加入的顺序输出，error取Error()，不能编码为JSON的值以fmt.Sprint转换为字符串。
+func (e *entry) appendJSON(buf []byte) []byte {
+	buf = append(buf, `{"ts":`...)
+	buf = appendJSONValue(buf, e.time.Format(time.RFC3339Nano))
+	buf = append(buf, `,"level":`...)
+	buf = appendJSONValue(buf, levelNames[e.level])
+	buf = append(buf, `,"caller":`...)
+	buf = appendJSONValue(buf, e.caller)
+	buf = append(buf, `,"msg":`...)
+	buf = appendJSONValue(buf, e.msg)
+	for _, f := range e.fields {
+		key := f.key
+		if reservedKeys[key] {
+			key = "fields." + key
+		}
+		buf = append(buf, ',')
+		buf = appendJSONValue(buf, key)
+		buf = append(buf, ':')
+		buf = appendJSONValue(buf, f.value)
+	}
+	return append(buf, '}', '\n')
+}
+
+func appendJSONValue(buf []byte, v interface{}) []byte {
+	if err, ok := v.(error); ok {
+		v = err.Error()
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(fmt.Sprint(v))
+	}
+	return append(buf, b...)
+}
+
+// JSON格式的输出，每条日志写一行。
+type jsonWriter struct {
+	mu    sync.Mutex
+	min   level     // 非请求日志的最低级别
+	sys   io.Writer // 所有非请求日志
+	err   io.Writer // 错误和危险日志，为nil时不写
+	req   io.Writer // 请求日志
+	files []*os.File
+}
+
+// 按opts打开日志文件，规则同文本格式：Console为true时系统日志同时写到标准输出，
+// Dir为空时所有日志只写到标准输出。
+func newJSONWriter(opts Options, min level) (*jsonWriter, error) {
+	w := &jsonWriter{min: min, req: os.Stdout}
+	var sys []io.Writer
+	if opts.Console || opts.Dir == "" {
+		sys = append(sys, os.Stdout)
+	}
+	if opts.Dir != "" {
+		for _, name := range []string{opts.SysFile, opts.ErrFile, opts.ReqFile} {
+			f, err := os.OpenFile(filepath.Join(opts.Dir, name), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+			if err != nil {
+				w.close()
+				return nil, err
+			}
+			w.files = append(w.files, f)
+		}
+		sys = append(sys, w.files[0])
+		w.err, w.req = w.files[1], w.files[2]
+	}
+	w.sys = io.MultiWriter(sys...)
+	return w, nil
+}
+
+func (w *jsonWriter) write(req bool, e *entry) {
+	if !req && e.level < w.min {
+		return
+	}
+	line := e.appendJSON(make([]byte, 0, 256))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if req {
+		w.req.Write(line)
+		return
+	}
+	w.sys.Write(line)
+	if e.level >= levelError && w.err != nil {
+		w.err.Write(line)
+	}
+}
+
+func (w *jsonWriter) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, f := range w.files {
+		f.Close()
+	}
+}
+
+// GetSysLogger在JSON格式下使用的beego日志适配器，写入当前的JSON输出。
+const jsonAdapter = "betterjun_json"
+
+func init() {
+	logs.Register(jsonAdapter, func() logs.Logger { return beeJSON{} })
+}
+
+type beeJSON struct{}
+
+func (beeJSON) Init(config string) error { return nil }
+func (beeJSON) Destroy()                 {}
+func (beeJSON) Flush()                   {}
+
+// beego在消息前加上级别标记、调用位置和前缀（默认为空）及一个空格，如"[I] [main.go:12]  msg"，分别解析。
+func (beeJSON) WriteMsg(when time.Time, msg string, lv int) error {
+	mu.RLock()
+	w := json_output
+	mu.RUnlock()
+	if w == nil {
+		return nil
+	}
+
+	e := &entry{time: when, level: fromBeeLevel(lv)}
+	if len(msg) >= 4 && msg[0] == '[' && msg[2] == ']' && msg[3] == ' ' {
+		msg = msg[4:]
+	}
+	if strings.HasPrefix(msg, "[") {
+		if i := strings.Index(msg, "] "); i > 0 && strings.Contains(msg[1:i], ":") && !strings.Contains(msg[1:i], " ") {
+			e.caller, msg = msg[1:i], msg[i+2:]
+		}
+	}
+	e.msg = strings.TrimPrefix(msg, " ")
+	w.write(false, e)
+	return nil
+}
@@ -4,37 +4,178 @@
 package logs
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
 	"runtime"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/astaxie/beego/logs"
 )
 
-// 系统全局日志
-var sys_logger *logs.BeeLogger
+// 日志初始化选项，见Init。
+type Options struct {
+	Dir     string // 日志文件目录，不存在时创建，为空时不写日志文件，所有日志包括请求日志输出到控制台
+	SysFile string // 系统日志文件名，为空时使用sys_log.txt
+	ErrFile string // 错误日志文件名，为空时使用err_log.txt
+	ReqFile string // 请求日志文件名，为空时使用req_log.txt
+	Console bool   // 系统日志和错误日志是否同时输出到控制台（标准输出），Dir为空时必须为true
+	Format  string // 输出格式，"text"（默认）为beego的文本格式，"json"为每行一个JSON对象，见With
+	Level   string // 系统日志和错误日志的最低级别，"trace"（默认）、"debug"、"info"、"warn"、"error"或"critical"，不影响请求日志
+}
 
-// 错误全局日志
-var err_logger *logs.BeeLogger
+// 返回与旧版本行为一致的选项：日志文件位于可执行文件所在目录的log子目录下，同时输出到控制台。
+func DefaultOptions() Options {
+	file, _ := exec.LookPath(os.Args[0])
+	return Options{Dir: path.Join(path.Dir(file), "log"), Console: true}
+}
 
-// 请求全局日志
-var req_logger *logs.BeeLogger
+var (
+	// 保护以下全局日志对象
+	mu sync.RWMutex
 
-// 初始化全局日志对象
-func init() {
-	file, _ := exec.LookPath(os.Args[0])
-	cpath := path.Dir(file)
+	// 系统全局日志，未初始化时为nil
+	sys_logger *logs.BeeLogger
 
-	sys_logger = NewLogger(path.Join(cpath, "log", "sys_log.txt"), true)
-	err_logger = NewLogger(path.Join(cpath, "log", "err_log.txt"), true)
-	req_logger = NewLogger(path.Join(cpath, "log", "req_log.txt"), false)
+	// 错误全局日志，未初始化时为nil
+	err_logger *logs.BeeLogger
+
+	// 请求全局日志，未初始化时为nil
+	req_logger *logs.BeeLogger
+
+	// JSON格式的输出，Format为"json"时使用，此时sys_logger写入json_output，另两个日志对象为nil
+	json_output *jsonWriter
+
+	// 未初始化时GetSysLogger返回的日志对象
+	console_logger *logs.BeeLogger
+)
+
+// 未初始化时日志输出到标准错误，初始化后控制台日志输出到标准输出。
+var stderr = log.New(os.Stderr, "", log.LstdFlags)
+
+// 初始化全局日志对象。导入本包时不创建任何文件，调用Init之前日志输出到标准错误。
+// 可以多次调用，原日志对象刷新后关闭。
+func Init(opts Options) error {
+	if opts.SysFile == "" {
+		opts.SysFile = "sys_log.txt"
+	}
+	if opts.ErrFile == "" {
+		opts.ErrFile = "err_log.txt"
+	}
+	if opts.ReqFile == "" {
+		opts.ReqFile = "req_log.txt"
+	}
+	if opts.Format != "" && opts.Format != "text" && opts.Format != "json" {
+		return fmt.Errorf("logs: unknown format %q", opts.Format)
+	}
+	if opts.Dir == "" && !opts.Console {
+		return errors.New("logs: no output, set Dir or Console")
+	}
+	min, err := parseLevel(opts.Level)
+	if err != nil {
+		return err
+	}
+	if opts.Dir != "" {
+		if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+			return err
+		}
+	}
+	if opts.Format == "json" {
+		w, err := newJSONWriter(opts, min)
+		if err != nil {
+			return err
+		}
+		// GetSysLogger返回的日志对象经适配器写入JSON输出
+		sys := logs.NewLogger(10240)
+		sys.EnableFuncCallDepth(true)
+		if err := sys.SetLogger(jsonAdapter, ""); err != nil {
+			w.close()
+			return err
+		}
+		swap(sys, nil, nil, w)
+		return nil
+	}
+
+	var created []*logs.BeeLogger
+	newLogger := func(name string, console bool) (*logs.BeeLogger, error) {
+		l := logs.NewLogger(10240) // 缓存大小：10240
+		created = append(created, l)
+		if console || opts.Dir == "" {
+			if err := l.SetLogger("console", ""); err != nil {
+				return nil, err
+			}
+		}
+		if opts.Dir != "" {
+			if err := l.SetLogger("file", fileConfig(filepath.ToSlash(filepath.Join(opts.Dir, name)))); err != nil {
+				return nil, err
+			}
+		}
+		return l, nil
+	}
+	sys, err := newLogger(opts.SysFile, opts.Console)
+	var errl, req *logs.BeeLogger
+	if err == nil {
+		errl, err = newLogger(opts.ErrFile, opts.Console)
+	}
+	if err == nil {
+		req, err = newLogger(opts.ReqFile, false)
+	}
+	if err != nil {
+		for _, l := range created {
+			l.Close()
+		}
+		return err
+	}
+	sys.SetLevel(beeLevels[min])
+	errl.SetLevel(beeLevels[min])
+
+	swap(sys, errl, req, nil)
+	return nil
+}
+
+// 刷新并关闭全局日志对象，此后日志输出到标准错误。
+func Close() {
+	swap(nil, nil, nil, nil)
 }
 
-// 返回日志对象,以访问所有的方法。
+// 替换全局日志对象，原日志对象刷新后关闭。
+func swap(sys, errl, req *logs.BeeLogger, w *jsonWriter) {
+	mu.Lock()
+	old := []*logs.BeeLogger{sys_logger, err_logger, req_logger}
+	oldJSON := json_output
+	sys_logger, err_logger, req_logger, json_output = sys, errl, req, w
+	mu.Unlock()
+	for _, l := range old {
+		if l != nil {
+			l.Flush()
+			l.Close()
+		}
+	}
+	if oldJSON != nil {
+		oldJSON.close()
+	}
+}
+
+// 返回日志对象,以访问所有的方法。未初始化时返回只输出到控制台的日志对象。
+// 使用JSON格式时通过该对象输出的日志同样编码为JSON，其SetLevel只影响该对象，不影响Info等函数。
 func GetSysLogger() *logs.BeeLogger {
-	return sys_logger
+	mu.Lock()
+	defer mu.Unlock()
+	if sys_logger != nil {
+		return sys_logger
+	}
+	if console_logger == nil {
+		console_logger = logs.NewLogger(10240)
+		console_logger.SetLogger("console", "")
+	}
+	return console_logger
 }
 
 // 初始化操作对象，并设置控制台显示以及文件记录。
@@ -43,55 +184,155 @@ func NewLogger(filename string, console bool) *logs.BeeLogger {
 	if console {
 		log.SetLogger("console", "")
 	}
-	json := `{"filename":"` + filename + `"}`
-	log.SetLogger("file", json)
+	log.SetLogger("file", fileConfig(filename))
 	return log
 }
 
-// skip为需要跳过的堆栈层数
-func getStackInfo(skip int) string {
+// beego文件日志的配置。
+func fileConfig(filename string) string {
+	b, _ := json.Marshal(map[string]string{"filename": filename})
+	return string(b)
+}
+
+// 日志级别。
+type level int
+
+const (
+	levelTrace level = iota
+	levelDebug
+	levelInfo
+	levelWarn
+	levelError
+	levelCritical
+)
+
+// JSON格式中的级别名称。
+var levelNames = [...]string{"trace", "debug", "info", "warn", "error", "critical"}
+
+// 对应的beego日志级别。
+var beeLevels = [...]int{logs.LevelDebug, logs.LevelDebug, logs.LevelInformational, logs.LevelWarning, logs.LevelError, logs.LevelCritical}
+
+// 解析级别名称，为空时为trace。
+func parseLevel(name string) (level, error) {
+	if name == "" {
+		return levelTrace, nil
+	}
+	for i, n := range levelNames {
+		if n == name {
+			return level(i), nil
+		}
+	}
+	return 0, fmt.Errorf("logs: unknown level %q", name)
+}
+
+// beego日志级别对应的级别。
+func fromBeeLevel(l int) level {
+	switch {
+	case l <= logs.LevelCritical:
+		return levelCritical
+	case l == logs.LevelError:
+		return levelError
+	case l == logs.LevelWarning:
+		return levelWarn
+	case l <= logs.LevelInformational:
+		return levelInfo
+	}
+	return levelDebug
+}
+
+// 未初始化时输出到标准错误的级别标记，与beego一致。
+var levelTags = [...]string{"[T]", "[D]", "[I]", "[W]", "[E]", "[C]"}
+
+// 写一条日志，req表示请求日志。skip为需要跳过的堆栈层数，0表示output的调用者。
+// 文本格式中fields以key=value的形式附加在消息之后。
+func output(lv level, req bool, skip int, msg string, fields []field) {
+	file, line, fn := getCaller(skip + 2)
+
+	mu.RLock()
+	sys, errl, reql, w := sys_logger, err_logger, req_logger, json_output
+	mu.RUnlock()
+
+	if w != nil {
+		w.write(req, &entry{
+			time:   time.Now(),
+			level:  lv,
+			caller: file + ":" + strconv.Itoa(line),
+			msg:    msg,
+			fields: fields,
+		})
+		return
+	}
+
+	text := fmt.Sprintf("[%s:%d:%s] ", file, line, fn) + msg + formatFields(fields)
+	if sys == nil {
+		stderr.Print(levelTags[lv] + " " + text)
+		return
+	}
+	if req {
+		reql.Debug("%s", text)
+		return
+	}
+	switch lv {
+	case levelTrace:
+		sys.Trace("%s", text)
+	case levelDebug:
+		sys.Debug("%s", text)
+	case levelInfo:
+		sys.Info("%s", text)
+	case levelWarn:
+		sys.Warn("%s", text)
+	case levelError:
+		sys.Error("%s", text)
+		errl.Error("%s", text)
+	case levelCritical:
+		sys.Critical("%s", text)
+		errl.Critical("%s", text)
+	}
+}
+
+// skip为需要跳过的堆栈层数，返回文件名、行号和函数名。
+func getCaller(skip int) (file string, line int, fn string) {
 	pc, file, line, _ := runtime.Caller(skip)
-	_, f := path.Split(file)
-	return fmt.Sprintf("[%s:%d:%s] ", f, line, runtime.FuncForPC(pc).Name())
+	_, file = path.Split(file)
+	if f := runtime.FuncForPC(pc); f != nil {
+		fn = f.Name()
+	}
+	return file, line, fn
 }
 
 //---------------------------------------------------------------------------
 // 全局普通日志便捷访问函数
 // 输出跟踪信息。
 func Trace(format string, v ...interface{}) {
-	sys_logger.Trace(getStackInfo(2)+format, v...)
+	output(levelTrace, false, 1, fmt.Sprintf(format, v...), nil)
 }
 
 // 输出调试信息。
 func Debug(format string, v ...interface{}) {
-	sys_logger.Debug(getStackInfo(2)+format, v...)
+	output(levelDebug, false, 1, fmt.Sprintf(format, v...), nil)
 }
 
 // 输出运行信息。
 func Info(format string, v ...interface{}) {
-	sys_logger.Info(getStackInfo(2)+format, v...)
+	output(levelInfo, false, 1, fmt.Sprintf(format, v...), nil)
 }
 
 // 输出错误消息。
 func Warn(format string, v ...interface{}) {
-	sys_logger.Warn(getStackInfo(2)+format, v...)
+	output(levelWarn, false, 1, fmt.Sprintf(format, v...), nil)
 }
 
 // 输出错误消息。
 func Error(format string, v ...interface{}) {
-	newfmt := getStackInfo(2) + format
-	sys_logger.Error(newfmt, v...)
-	err_logger.Error(newfmt, v...)
+	output(levelError, false, 1, fmt.Sprintf(format, v...), nil)
 }
 
 // 输出危险消息。
 func Critical(format string, v ...interface{}) {
-	newfmt := getStackInfo(2) + format
-	sys_logger.Critical(newfmt, v...)
-	err_logger.Critical(newfmt, v...)
+	output(levelCritical, false, 1, fmt.Sprintf(format, v...), nil)
 }
 
 // 输出Http restful请求消息，在单独的日志文件中记录。
 func LogRequest(format string, v ...interface{}) {
-	req_logger.Debug(getStackInfo(2)+format, v...)
+	output(levelDebug, true, 1, fmt.Sprintf(format, v...), nil)
 }
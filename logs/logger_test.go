@@ -0,0 +1,87 @@
+package logs
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFallback(t *testing.T) {
+	var buf bytes.Buffer
+	saved := stderr
+	stderr = log.New(&buf, "", 0)
+	defer func() { stderr = saved }()
+
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	Info("hello %d", 1)
+	Error("oops")
+	out := buf.String()
+	if !strings.Contains(out, "[I] [logger_test.go:") || !strings.Contains(out, "hello 1") {
+		t.Errorf("Info output = %q", out)
+	}
+	if n := strings.Count(out, "oops"); n != 1 {
+		t.Errorf("Error written %d times, want 1", n)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("files created before Init: %v", entries)
+	}
+	if GetSysLogger() == nil {
+		t.Error("GetSysLogger() = nil before Init")
+	}
+}
+
+func TestInit(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "a", "log")
+	if err := Init(Options{Dir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		t.Fatalf("log dir not created: %v", err)
+	}
+	mu.RLock()
+	sys, errl, req := sys_logger, err_logger, req_logger
+	mu.RUnlock()
+	if sys == nil || errl == nil || req == nil {
+		t.Fatal("loggers not set after Init")
+	}
+	if GetSysLogger() == console_logger {
+		t.Error("GetSysLogger() returned the fallback logger after Init")
+	}
+
+	Close()
+	if GetSysLogger() != console_logger {
+		t.Error("sys logger still set after Close")
+	}
+}
+
+func TestInitNoOutput(t *testing.T) {
+	if err := Init(Options{}); err == nil {
+		Close()
+		t.Fatal("Init without Dir and Console should fail")
+	}
+	mu.RLock()
+	sys := sys_logger
+	mu.RUnlock()
+	if sys != nil {
+		t.Error("failed Init should not replace the loggers")
+	}
+}
+
+func TestFileConfig(t *testing.T) {
+	name := `C:\logs\"a"/sys_log.txt`
+	var conf map[string]string
+	if err := json.Unmarshal([]byte(fileConfig(name)), &conf); err != nil || conf["filename"] != name {
+		t.Errorf("fileConfig = %q, %v", fileConfig(name), err)
+	}
+}
@@ -0,0 +1,222 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ValidTag is the struct tag name for validation rules, e.g. `valid:"Required;Range(1,100)"`
+const ValidTag = "valid"
+
+// ValidateTag parses the rules in tag and applies them to obj in order.
+// Rules are separated by ";", arguments of Match and NoMatch are regexps enclosed in "/".
+// Without Required, an empty string or nil obj is optional and skips the other rules.
+func (vg *ValidationGroup) ValidateTag(obj interface{}, name, tag string) (*Validation, error) {
+	rules, err := ParseTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	v := vg.Validate(obj, name)
+	if obj == nil || obj == "" {
+		required := false
+		for _, r := range rules {
+			required = required || r.Name == "Required"
+		}
+		if !required {
+			return v, nil
+		}
+	}
+	for _, r := range rules {
+		if err := r.apply(v); err != nil {
+			return v, err
+		}
+	}
+	return v, nil
+}
+
+// Rule is a parsed validation rule, e.g. Range(1,100) has Name "Range", Args ["1", "100"]
+// and Ints [1, 100]. ParseTag checks the number of arguments, so Ints holds as many
+// values as the rule takes; Match and NoMatch keep their regexp in Args and have no Ints.
+type Rule struct {
+	Name string
+	Args []string
+	Ints []int
+}
+
+// parseArgs checks the number of arguments and parses integer arguments into r.Ints.
+func (r *Rule) parseArgs() error {
+	if r.Name == "Match" || r.Name == "NoMatch" {
+		if len(r.Args) != 1 {
+			return fmt.Errorf("%s requires 1 argument", r.Name)
+		}
+		return nil
+	}
+
+	ints := make([]int, len(r.Args))
+	for i, a := range r.Args {
+		n, err := strconv.Atoi(strings.TrimSpace(a))
+		if err != nil {
+			return fmt.Errorf("%s: invalid argument %q", r.Name, a)
+		}
+		ints[i] = n
+	}
+
+	var want int
+	switch r.Name {
+	case "Min", "Max", "Length", "MinLength", "MaxLength":
+		want = 1
+	case "Range":
+		want = 2
+	}
+	if len(ints) != want {
+		return fmt.Errorf("%s requires %d arguments", r.Name, want)
+	}
+	if want > 0 {
+		r.Ints = ints
+	}
+	return nil
+}
+
+func (r Rule) apply(v *Validation) error {
+	switch r.Name {
+	case "Match", "NoMatch":
+		re, err := regexp.Compile(r.Args[0])
+		if err != nil {
+			return fmt.Errorf("%s: %v", r.Name, err)
+		}
+		if r.Name == "Match" {
+			v.Match(re)
+		} else {
+			v.NoMatch(re)
+		}
+	case "Required":
+		v.Required()
+	case "Min":
+		v.Min(r.Ints[0])
+	case "Max":
+		v.Max(r.Ints[0])
+	case "Range":
+		v.Range(r.Ints[0], r.Ints[1])
+	case "Length":
+		v.Length(r.Ints[0])
+	case "MinLength":
+		v.MinLength(r.Ints[0])
+	case "MaxLength":
+		v.MaxLength(r.Ints[0])
+	case "Alpha":
+		v.Alpha()
+	case "Numeric":
+		v.Numeric()
+	case "AlphaNumeric":
+		v.AlphaNumeric()
+	case "AlphaDash":
+		v.AlphaDash()
+	case "Email":
+		v.Email()
+	case "IP":
+		v.IP()
+	case "Base64":
+		v.Base64()
+	case "Mobile":
+		v.Mobile()
+	case "Tel":
+		v.Tel()
+	case "Phone":
+		v.Phone()
+	case "ZipCode":
+		v.ZipCode()
+	default:
+		return fmt.Errorf("unknown validator %q", r.Name)
+	}
+	return nil
+}
+
+// ParseTag splits tag into rules without applying them, keeping ";" and "," inside regexps,
+// and checks their arguments. See ValidateTag for the syntax.
+func ParseTag(tag string) ([]Rule, error) {
+	rules, err := splitRules(tag)
+	if err != nil {
+		return nil, err
+	}
+	for i := range rules {
+		if err := rules[i].parseArgs(); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+func splitRules(tag string) ([]Rule, error) {
+	var rules []Rule
+	for s := strings.TrimSpace(tag); s != ""; s = strings.TrimSpace(s) {
+		if s[0] == ';' {
+			s = s[1:]
+			continue
+		}
+
+		end := strings.IndexAny(s, "(;")
+		if end < 0 {
+			rules = append(rules, Rule{Name: s})
+			break
+		}
+		r := Rule{Name: strings.TrimSpace(s[:end])}
+		if s[end] == ';' {
+			rules = append(rules, r)
+			s = s[end+1:]
+			continue
+		}
+
+		args, rest, err := parseArgs(s[end+1:])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", r.Name, err)
+		}
+		r.Args = args
+		rules = append(rules, r)
+		s = rest
+	}
+	return rules, nil
+}
+
+// parseArgs parses the arguments after "(" and returns the text after ")".
+func parseArgs(s string) (args []string, rest string, err error) {
+	for {
+		s = strings.TrimLeft(s, " ")
+		if s == "" {
+			return nil, "", fmt.Errorf("missing )")
+		}
+		if s[0] == ')' && len(args) == 0 {
+			return nil, s[1:], nil
+		}
+
+		// a regexp is the only argument and ends with "/)"
+		if s[0] == '/' {
+			end := strings.Index(s[1:], "/)")
+			if end < 0 {
+				return nil, "", fmt.Errorf("unterminated regexp")
+			}
+			return append(args, s[1:end+1]), s[end+3:], nil
+		}
+
+		end := strings.IndexAny(s, ",)")
+		if end < 0 {
+			return nil, "", fmt.Errorf("missing )")
+		}
+		args = append(args, strings.TrimSpace(s[:end]))
+		s = s[end:]
+
+		s = strings.TrimLeft(s, " ")
+		if s == "" {
+			return nil, "", fmt.Errorf("missing )")
+		}
+		if s[0] == ')' {
+			return args, s[1:], nil
+		}
+		if s[0] != ',' {
+			return nil, "", fmt.Errorf("unexpected %q", s[0])
+		}
+		s = s[1:]
+	}
+}
@@ -15,6 +15,7 @@
 package validation
 
 import (
+	"reflect"
 	"testing"
 	"time"
 )
@@ -330,3 +331,50 @@ func TestBase64(t *testing.T) {
 // 		t.Error("\"536000\" is a valid zipcode should be true")
 // 	}
 // }
+
+func TestValidateTag(t *testing.T) {
+	vg := ValidationGroup{}
+	v, err := vg.ValidateTag(50, "pool", "Required;Range(1, 100)")
+	if err != nil || !v.Passed() {
+		t.Error("failed", err)
+	}
+	v, err = vg.ValidateTag(0, "pool", "Required;Range(1,100)")
+	if err != nil || len(v.GetErrors()) != 2 {
+		t.Error("failed", err, v.GetErrors())
+	}
+	v, err = vg.ValidateTag("ab;c", "name", "Match(/^[a-z;]+$/);MaxLength(3)")
+	if err != nil || len(v.GetErrors()) != 1 {
+		t.Error("failed", err, v.GetErrors())
+	}
+	v, err = vg.ValidateTag("127.0.0.1", "host", "IP;NoMatch(/^0/)")
+	if err != nil || !v.Passed() {
+		t.Error("failed", err)
+	}
+	v, err = vg.ValidateTag("", "optional", "Email")
+	if err != nil || !v.Passed() {
+		t.Error("empty optional value should pass", err)
+	}
+
+	for _, tag := range []string{"Unknown", "Range(1)", "Min(a)", "Match(/x", "Max(1"} {
+		if _, err := vg.ValidateTag(1, "bad", tag); err == nil {
+			t.Errorf("tag %q should fail", tag)
+		}
+	}
+}
+
+func TestParseTag(t *testing.T) {
+	rules, err := ParseTag("Required; Range(1, 100);Match(/^a,b$/)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Rule{{Name: "Required"}, {Name: "Range", Args: []string{"1", "100"}, Ints: []int{1, 100}}, {Name: "Match", Args: []string{"^a,b$"}}}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("ParseTag = %+v", rules)
+	}
+
+	for _, tag := range []string{"Range(1)", "Min(x)", "Match", "Required(1)"} {
+		if _, err := ParseTag(tag); err == nil {
+			t.Errorf("ParseTag(%q) should fail", tag)
+		}
+	}
+}